@@ -0,0 +1,287 @@
+package booster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// Booster is a gradient boosted tree model as saved via the "save_model"
+// Python API of XGBoost, either in universal binary JSON or in JSON format.
+// Predictions are computed natively by walking the boosted trees, without
+// any dependency on XGBoost itself.
+//
+//     bst, err := booster.Load("/Users/xh3b4sd/dat/ensemble.ubj")
+//     if err != nil {
+//         panic(err)
+//     }
+//
+//     pre := bst.Predict([]float32{ ... })
+//
+type Booster struct {
+	// bas is the base score translated into margin space.
+	bas float32
+	// bes is the best iteration as tracked by early stopping, or -1 if there
+	// is none.
+	bes int
+	// cla is the number of classes for multiclass objectives, 0 otherwise.
+	cla int
+	// grp is the number of output groups, that is the number of classes for
+	// multiclass objectives, 1 otherwise.
+	grp int
+	// ind contains the tree offsets per boosting round, where the trees of
+	// round i are tre[ind[i]:ind[i+1]].
+	ind []int
+	obj string
+	tre []tree
+}
+
+type tree struct {
+	// cat contains the category sets of categorical split nodes.
+	cat map[int]map[float64]bool
+	con []float32
+	def []bool
+	grp int
+	idx []int
+	lef []int
+	rig []int
+}
+
+// Load reads and parses the saved model at the given file path.
+func Load(pat string) (*Booster, error) {
+	byt, err := ioutil.ReadFile(pat)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	bst, err := Parse(byt)
+	if err != nil {
+		return nil, tracer.Maskf(invalidModelError, "%s: %s", pat, err)
+	}
+
+	return bst, nil
+}
+
+// Parse parses the given saved model, which may either be encoded in
+// universal binary JSON or in JSON.
+func Parse(byt []byte) (*Booster, error) {
+	var err error
+
+	var val interface{}
+	if isjson(byt) {
+		dec := json.NewDecoder(bytes.NewReader(byt))
+		dec.UseNumber()
+
+		err = dec.Decode(&val)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	} else {
+		dec := &ubjson{rea: bytes.NewReader(byt)}
+
+		val, err = dec.decode()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var bst *Booster
+	{
+		bst, err = decode(val)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	return bst, nil
+}
+
+// BestIteration returns the best iteration as tracked by early stopping
+// during training, or -1 if there is none.
+func (b *Booster) BestIteration() int {
+	return b.bes
+}
+
+// Classes returns the number of classes for multiclass objectives, 0
+// otherwise.
+func (b *Booster) Classes() int {
+	return b.cla
+}
+
+// Groups returns the number of output groups, which is the number of classes
+// for multiclass objectives and 1 otherwise.
+func (b *Booster) Groups() int {
+	return b.grp
+}
+
+// Margin returns the untransformed prediction per output group for the given
+// feature vector. Missing features are represented by NaN. Only the trees up
+// to and including the best iteration are used, which is equivalent to the
+// following Python call.
+//
+//     bst.predict(mat, iteration_range=(0, bst.best_iteration + 1), output_margin=True)
+//
+func (b *Booster) Margin(fea []float32) []float32 {
+	mar := make([]float32, b.grp)
+	for i := range mar {
+		mar[i] = b.bas
+	}
+
+	for _, t := range b.tre[:b.limit()] {
+		mar[t.grp] += t.con[t.leaf(fea)]
+	}
+
+	return mar
+}
+
+// Objective returns the name of the learning objective, e.g. reg:logistic.
+func (b *Booster) Objective() string {
+	return b.obj
+}
+
+// Predict returns the transformed prediction for the given feature vector
+// according to the learning objective. Multiclass objectives using softprob
+// return one probability per class. Multiclass objectives using softmax
+// return the predicted class as single value. All other objectives return a
+// single value.
+//
+//     bst.predict(mat, iteration_range=(0, bst.best_iteration + 1))
+//
+func (b *Booster) Predict(fea []float32) []float32 {
+	mar := b.Margin(fea)
+
+	switch b.obj {
+	case "binary:logistic", "reg:logistic":
+		for i := range mar {
+			mar[i] = sigmoid(mar[i])
+		}
+	case "count:poisson", "reg:gamma", "reg:tweedie":
+		for i := range mar {
+			mar[i] = expf(mar[i])
+		}
+	case "multi:softmax":
+		mar = []float32{float32(argmax(mar))}
+	case "multi:softprob":
+		softmax(mar)
+	}
+
+	return mar
+}
+
+// limit returns the number of trees used for predictions, given the best
+// iteration if any.
+func (b *Booster) limit() int {
+	if b.bes < 0 || b.bes+1 >= len(b.ind) {
+		return len(b.tre)
+	}
+
+	return b.ind[b.bes+1]
+}
+
+// leaf returns the node ID of the leaf the given feature vector lands in. The
+// decision rules follow the implementation of XGBoost. Numerical features go
+// left if they are less than the split condition. Categorical features go
+// right if they are part of the category set of the split node. Missing
+// features follow the default direction of the split node.
+func (t tree) leaf(fea []float32) int {
+	var nid int
+
+	for t.lef[nid] != -1 {
+		var val float32
+		var mis bool
+		{
+			idx := t.idx[nid]
+			if idx >= len(fea) || math.IsNaN(float64(fea[idx])) {
+				mis = true
+			} else {
+				val = fea[idx]
+			}
+		}
+
+		if mis {
+			if t.def[nid] {
+				nid = t.lef[nid]
+			} else {
+				nid = t.rig[nid]
+			}
+		} else if set, ok := t.cat[nid]; ok {
+			if val >= 0 && set[math.Trunc(float64(val))] {
+				nid = t.rig[nid]
+			} else {
+				nid = t.lef[nid]
+			}
+		} else {
+			if val < t.con[nid] {
+				nid = t.lef[nid]
+			} else {
+				nid = t.rig[nid]
+			}
+		}
+	}
+
+	return nid
+}
+
+// argmax returns the index of the largest value of x.
+func argmax(x []float32) int {
+	var max int
+	for i := range x {
+		if x[i] > x[max] {
+			max = i
+		}
+	}
+
+	return max
+}
+
+func expf(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+// isjson returns whether the given saved model is encoded in JSON rather than
+// universal binary JSON. Both formats start with an opening curly brace. In
+// JSON the next meaningful character is a quote or a closing curly brace, in
+// universal binary JSON it is a type marker.
+func isjson(byt []byte) bool {
+	byt = bytes.TrimLeft(byt, " \t\r\n")
+	if len(byt) == 0 || byt[0] != '{' {
+		return false
+	}
+
+	byt = bytes.TrimLeft(byt[1:], " \t\r\n")
+	if len(byt) == 0 {
+		return false
+	}
+
+	return byt[0] == '"' || byt[0] == '}'
+}
+
+func logf(x float32) float32 {
+	return float32(math.Log(float64(x)))
+}
+
+func sigmoid(x float32) float32 {
+	return 1 / (1 + expf(-x))
+}
+
+func softmax(x []float32) {
+	var max float32 = x[0]
+	for _, v := range x {
+		if v > max {
+			max = v
+		}
+	}
+
+	var sum float32
+	for i := range x {
+		x[i] = expf(x[i] - max)
+		sum += x[i]
+	}
+
+	for i := range x {
+		x[i] /= sum
+	}
+}
@@ -0,0 +1,300 @@
+package booster
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The models in testdata are written by hand in the format of the
+// "save_model" Python API of XGBoost 2.0, and the expected margins follow
+// from walking their trees by the decision rules of XGBoost. numeric.ubj is
+// numeric.json encoded in universal binary JSON the way XGBoost writes it,
+// with typed arrays and int64 lengths.
+//
+//     numeric.json        reg:logistic, numeric splits, missing values and
+//                         a best iteration excluding the last tree
+//     categorical.json    reg:squarederror, a categorical split and no
+//                         iteration_indptr, like models of XGBoost < 1.7
+//     multiclass.json     multi:softprob with 3 classes
+//
+// The models in testdata/xgboost are saved by XGBoost itself, together with
+// the margins and predictions of XGBoost, by running generate.py in there.
+// Test_Booster_Xgboost compares against all of them and is skipped as long as
+// none are generated.
+//
+
+func Test_Booster_Categorical(t *testing.T) {
+	nan := float32(math.NaN())
+
+	testCases := []struct {
+		fea []float32
+		mar float32
+	}{
+		// Case 0, where category 1 is part of the category set and goes right.
+		{
+			fea: []float32{1, 0.5},
+			mar: 3.0,
+		},
+		// Case 1
+		{
+			fea: []float32{3, -1},
+			mar: 3.0,
+		},
+		// Case 2, where category 2 goes left.
+		{
+			fea: []float32{2, -1},
+			mar: 0.0,
+		},
+		// Case 3
+		{
+			fea: []float32{0, 0.5},
+			mar: 1.5,
+		},
+		// Case 4, where the missing category follows the default direction.
+		{
+			fea: []float32{nan, 0.5},
+			mar: 1.5,
+		},
+		// Case 5, where negative categories are invalid and go left.
+		{
+			fea: []float32{-1, -2},
+			mar: 0.0,
+		},
+		// Case 6, where categories are truncated to integers.
+		{
+			fea: []float32{3.7, 0.5},
+			mar: 3.0,
+		},
+		// Case 7, where categories beyond the category set go left.
+		{
+			fea: []float32{100, 0.5},
+			mar: 1.5,
+		},
+	}
+
+	bst := load(t, "categorical.json")
+
+	if bst.BestIteration() != -1 {
+		t.Fatalf("expected best iteration -1, got %d", bst.BestIteration())
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			equal(t, bst.Margin(tc.fea), []float32{tc.mar})
+			equal(t, bst.Predict(tc.fea), []float32{tc.mar})
+		})
+	}
+}
+
+func Test_Booster_Multiclass(t *testing.T) {
+	testCases := []struct {
+		fea []float32
+		mar []float32
+		pre []float32
+	}{
+		// Case 0
+		{
+			fea: []float32{0},
+			mar: []float32{0.8, 0.3, 0.6},
+			pre: []float32{0.41232669, 0.25008878, 0.33758454},
+		},
+		// Case 1
+		{
+			fea: []float32{2},
+			mar: []float32{0.0, 0.9, 0.5},
+			pre: []float32{0.19575891, 0.48148922, 0.32275187},
+		},
+		// Case 2, where the missing feature follows the default direction.
+		{
+			fea: []float32{float32(math.NaN())},
+			mar: []float32{0.8, 0.3, 0.6},
+			pre: []float32{0.41232669, 0.25008878, 0.33758454},
+		},
+	}
+
+	bst := load(t, "multiclass.json")
+
+	if bst.Classes() != 3 || bst.Groups() != 3 {
+		t.Fatalf("expected 3 classes and groups, got %d and %d", bst.Classes(), bst.Groups())
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			equal(t, bst.Margin(tc.fea), tc.mar)
+			equal(t, bst.Predict(tc.fea), tc.pre)
+		})
+	}
+}
+
+func Test_Booster_Numeric(t *testing.T) {
+	nan := float32(math.NaN())
+
+	testCases := []struct {
+		fea []float32
+		mar float32
+		pre float32
+	}{
+		// Case 0
+		{
+			fea: []float32{0.2, 1.0},
+			mar: 0.05,
+			pre: 0.51249740,
+		},
+		// Case 1
+		{
+			fea: []float32{0.7, 5.0},
+			mar: 0.45,
+			pre: 0.61063923,
+		},
+		// Case 2, where values equal to the split condition go right.
+		{
+			fea: []float32{0.5, 2.0},
+			mar: 0.45,
+			pre: 0.61063923,
+		},
+		// Case 3, where the missing feature goes left by default.
+		{
+			fea: []float32{nan, 3.0},
+			mar: 0.4,
+			pre: 0.59868766,
+		},
+		// Case 4, where the missing feature goes right by default.
+		{
+			fea: []float32{0.2, nan},
+			mar: 0.4,
+			pre: 0.59868766,
+		},
+		// Case 5, where features beyond the feature vector are missing.
+		{
+			fea: []float32{0.2},
+			mar: 0.4,
+			pre: 0.59868766,
+		},
+		// Case 6
+		{
+			fea: []float32{0.2, -3.0},
+			mar: -0.5,
+			pre: 0.37754067,
+		},
+	}
+
+	for _, f := range []string{"numeric.json", "numeric.ubj"} {
+		bst := load(t, f)
+
+		if bst.BestIteration() != 1 {
+			t.Fatalf("expected best iteration 1, got %d", bst.BestIteration())
+		}
+
+		for i, tc := range testCases {
+			t.Run(f+"/"+strconv.Itoa(i), func(t *testing.T) {
+				equal(t, bst.Margin(tc.fea), []float32{tc.mar})
+				equal(t, bst.Predict(tc.fea), []float32{tc.pre})
+			})
+		}
+	}
+}
+
+func Test_Booster_Xgboost(t *testing.T) {
+	pat, err := filepath.Glob(filepath.Join("testdata", "xgboost", "*.pre.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pat) == 0 {
+		t.Skip("no models saved by XGBoost, see testdata/xgboost/generate.py")
+	}
+
+	for _, p := range pat {
+		var fix struct {
+			Bes int          `json:"bes"`
+			Fea [][]*float32 `json:"fea"`
+			Mar [][]float32  `json:"mar"`
+			Pre [][]float32  `json:"pre"`
+			Ver string       `json:"ver"`
+		}
+
+		{
+			byt, err := ioutil.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = json.Unmarshal(byt, &fix)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		nam := strings.TrimSuffix(filepath.Base(p), ".pre.json")
+
+		for _, e := range []string{".json", ".ubj"} {
+			bst := load(t, filepath.Join("xgboost", nam+e))
+
+			if bst.BestIteration() != fix.Bes {
+				t.Fatalf("expected best iteration %d of %s%s saved by XGBoost %s, got %d", fix.Bes, nam, e, fix.Ver, bst.BestIteration())
+			}
+
+			for i := range fix.Fea {
+				t.Run(nam+e+"/"+strconv.Itoa(i), func(t *testing.T) {
+					fea := make([]float32, len(fix.Fea[i]))
+					for j, v := range fix.Fea[i] {
+						if v == nil {
+							fea[j] = float32(math.NaN())
+						} else {
+							fea[j] = *v
+						}
+					}
+
+					approx(t, bst.Margin(fea), fix.Mar[i])
+					approx(t, bst.Predict(fea), fix.Pre[i])
+				})
+			}
+		}
+	}
+}
+
+// approx is like equal, with a relative tolerance for outputs of XGBoost,
+// which sums leaf values in a different order.
+func approx(t *testing.T, act []float32, exp []float32) {
+	t.Helper()
+
+	if len(act) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, act)
+	}
+
+	for i := range exp {
+		if math.Abs(float64(act[i]-exp[i])) > 1e-5*math.Max(1, math.Abs(float64(exp[i]))) {
+			t.Fatalf("expected %v, got %v", exp, act)
+		}
+	}
+}
+
+func equal(t *testing.T, act []float32, exp []float32) {
+	t.Helper()
+
+	if len(act) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, act)
+	}
+
+	for i := range exp {
+		if math.Abs(float64(act[i]-exp[i])) > 1e-6 {
+			t.Fatalf("expected %v, got %v", exp, act)
+		}
+	}
+}
+
+func load(t *testing.T, nam string) *Booster {
+	t.Helper()
+
+	bst, err := Load(filepath.Join("testdata", nam))
+	if err != nil {
+		t.Fatalf("expected %s to load, got %#v", nam, err)
+	}
+
+	return bst
+}
@@ -0,0 +1,507 @@
+package booster
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// decode translates the generic representation of a saved model, as produced
+// by either encoding/json or ubjson, into a Booster.
+//
+//     {
+//         "learner": {
+//             "attributes": { "best_iteration": "12", ... },
+//             "gradient_booster": {
+//                 "model": {
+//                     "gbtree_model_param": { "num_parallel_tree": "1", ... },
+//                     "tree_info": [ ... ],
+//                     "trees": [ ... ]
+//                 },
+//                 "name": "gbtree"
+//             },
+//             "learner_model_param": { "base_score": "5E-1", "num_class": "0", ... },
+//             "objective": { "name": "reg:logistic", ... }
+//         },
+//         "version": [ 1, 7, 1 ]
+//     }
+//
+func decode(val interface{}) (*Booster, error) {
+	var err error
+
+	var lea map[string]interface{}
+	{
+		lea, err = object(val, "learner")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var gbm map[string]interface{}
+	{
+		gbm, err = object(lea, "gradient_booster")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		nam, err := text(gbm["name"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		if nam != "gbtree" {
+			return nil, tracer.Maskf(invalidModelError, "booster %q is not supported", nam)
+		}
+	}
+
+	var mod map[string]interface{}
+	{
+		mod, err = object(gbm, "model")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var par map[string]interface{}
+	{
+		par, err = object(lea, "learner_model_param")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	b := &Booster{}
+
+	{
+		b.cla, err = integer(par["num_class"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		b.grp = b.cla
+		if b.grp == 0 {
+			b.grp = 1
+		}
+	}
+
+	{
+		var obj map[string]interface{}
+		obj, err = object(lea, "objective")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		b.obj, err = text(obj["name"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		var bas float64
+		bas, err = number(par["base_score"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		b.bas, err = margin(b.obj, bas)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		b.bes = -1
+
+		att, _ := lea["attributes"].(map[string]interface{})
+		if att != nil && att["best_iteration"] != nil {
+			b.bes, err = integer(att["best_iteration"])
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+	}
+
+	var tin []interface{}
+	{
+		tin, err = array(mod["tree_info"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var lis []interface{}
+	{
+		lis, err = array(mod["trees"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		if len(lis) != len(tin) {
+			return nil, tracer.Maskf(invalidModelError, "found %d trees but %d tree infos", len(lis), len(tin))
+		}
+	}
+
+	for i := range lis {
+		var t tree
+
+		t, err = decodeTree(lis[i])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		t.grp, err = integer(tin[i])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		if t.grp < 0 || t.grp >= b.grp {
+			return nil, tracer.Maskf(invalidModelError, "tree %d has invalid output group %d", i, t.grp)
+		}
+
+		b.tre = append(b.tre, t)
+	}
+
+	// Newer versions of XGBoost keep track of the trees per boosting round
+	// explicitly. Older versions imply a fixed number of trees per boosting
+	// round, given the number of parallel trees and output groups.
+	if mod["iteration_indptr"] != nil {
+		var ind []interface{}
+		ind, err = array(mod["iteration_indptr"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		for _, x := range ind {
+			var i int
+			i, err = integer(x)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			b.ind = append(b.ind, i)
+		}
+	} else {
+		var gtp map[string]interface{}
+		gtp, err = object(mod, "gbtree_model_param")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		var npt int
+		npt, err = integer(gtp["num_parallel_tree"])
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		per := npt * b.grp
+		if per <= 0 {
+			return nil, tracer.Maskf(invalidModelError, "invalid number of trees per boosting round")
+		}
+
+		for i := 0; i < len(b.tre); i += per {
+			b.ind = append(b.ind, i)
+		}
+
+		b.ind = append(b.ind, len(b.tre))
+	}
+
+	return b, nil
+}
+
+func decodeTree(val interface{}) (tree, error) {
+	var err error
+
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return tree{}, tracer.Maskf(invalidModelError, "tree must be an object")
+	}
+
+	var t tree
+
+	{
+		t.lef, err = integers(obj["left_children"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+		t.rig, err = integers(obj["right_children"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+		t.idx, err = integers(obj["split_indices"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+		t.con, err = floats(obj["split_conditions"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+		t.def, err = booleans(obj["default_left"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+	}
+
+	{
+		num := len(t.lef)
+		if num == 0 || len(t.rig) != num || len(t.idx) != num || len(t.con) != num || len(t.def) != num {
+			return tree{}, tracer.Maskf(invalidModelError, "tree arrays must not be empty and of equal length")
+		}
+
+		for i := 0; i < num; i++ {
+			if t.lef[i] < -1 || t.lef[i] >= num || t.rig[i] < -1 || t.rig[i] >= num || (t.lef[i] == -1) != (t.rig[i] == -1) {
+				return tree{}, tracer.Maskf(invalidModelError, "tree node %d has invalid children", i)
+			}
+		}
+
+		// Every node must be reachable from the root at most once, which rules
+		// out cycles when walking the tree.
+		see := make([]bool, num)
+		que := []int{0}
+		for len(que) != 0 {
+			nid := que[0]
+			que = que[1:]
+
+			if see[nid] {
+				return tree{}, tracer.Maskf(invalidModelError, "tree node %d is reachable more than once", nid)
+			}
+
+			see[nid] = true
+
+			if t.lef[nid] != -1 {
+				que = append(que, t.lef[nid], t.rig[nid])
+			}
+		}
+	}
+
+	// Categorical splits are only present in models trained with categorical
+	// data support. The categories of a split node are stored as segments of
+	// one flat list.
+	var typ []int
+	if obj["split_type"] != nil {
+		typ, err = integers(obj["split_type"])
+		if err != nil {
+			return tree{}, tracer.Mask(err)
+		}
+	}
+
+	for i, x := range typ {
+		if x != 1 {
+			continue
+		}
+
+		if t.cat == nil {
+			t.cat = map[int]map[float64]bool{}
+		}
+
+		t.cat[i] = map[float64]bool{}
+	}
+
+	if t.cat != nil {
+		var nod, seg, siz []int
+		var cat []float64
+		{
+			nod, err = integers(obj["categories_nodes"])
+			if err != nil {
+				return tree{}, tracer.Mask(err)
+			}
+			seg, err = integers(obj["categories_segments"])
+			if err != nil {
+				return tree{}, tracer.Mask(err)
+			}
+			siz, err = integers(obj["categories_sizes"])
+			if err != nil {
+				return tree{}, tracer.Mask(err)
+			}
+			cat, err = numbers(obj["categories"])
+			if err != nil {
+				return tree{}, tracer.Mask(err)
+			}
+		}
+
+		if len(nod) != len(seg) || len(nod) != len(siz) {
+			return tree{}, tracer.Maskf(invalidModelError, "tree categories must be of equal length")
+		}
+
+		for i, n := range nod {
+			if t.cat[n] == nil || seg[i] < 0 || siz[i] < 0 || seg[i]+siz[i] > len(cat) {
+				return tree{}, tracer.Maskf(invalidModelError, "tree node %d has invalid categories", n)
+			}
+
+			for _, c := range cat[seg[i] : seg[i]+siz[i]] {
+				t.cat[n][c] = true
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// margin translates the given base score into the margin space of the given
+// objective, the same way XGBoost does before accumulating the leaf values of
+// the boosted trees.
+func margin(obj string, bas float64) (float32, error) {
+	switch obj {
+	case "binary:logistic", "binary:logitraw", "reg:logistic":
+		if bas <= 0 || bas >= 1 {
+			return 0, tracer.Maskf(invalidModelError, "base score %f must be within (0, 1)", bas)
+		}
+		return -logf(1/float32(bas) - 1), nil
+	case "count:poisson", "reg:gamma", "reg:tweedie":
+		if bas <= 0 {
+			return 0, tracer.Maskf(invalidModelError, "base score %f must be positive", bas)
+		}
+		return logf(float32(bas)), nil
+	case "multi:softmax", "multi:softprob", "reg:absoluteerror", "reg:pseudohubererror", "reg:squarederror", "reg:squaredlogerror":
+		return float32(bas), nil
+	}
+
+	return 0, tracer.Maskf(invalidModelError, "objective %q is not supported", obj)
+}
+
+func array(val interface{}) ([]interface{}, error) {
+	lis, ok := val.([]interface{})
+	if !ok {
+		return nil, tracer.Maskf(invalidModelError, "expected array, got %T", val)
+	}
+
+	return lis, nil
+}
+
+func booleans(val interface{}) ([]bool, error) {
+	lis, err := array(val)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var boo []bool
+	for _, x := range lis {
+		switch v := x.(type) {
+		case bool:
+			boo = append(boo, v)
+		default:
+			n, err := number(v)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			boo = append(boo, n != 0)
+		}
+	}
+
+	return boo, nil
+}
+
+func floats(val interface{}) ([]float32, error) {
+	lis, err := numbers(val)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var flo []float32
+	for _, x := range lis {
+		flo = append(flo, float32(x))
+	}
+
+	return flo, nil
+}
+
+func integer(val interface{}) (int, error) {
+	num, err := number(val)
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return int(num), nil
+}
+
+func integers(val interface{}) ([]int, error) {
+	lis, err := numbers(val)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var num []int
+	for _, x := range lis {
+		num = append(num, int(x))
+	}
+
+	return num, nil
+}
+
+// number parses any numeric value. Note that XGBoost stores most of its model
+// parameters as strings, e.g. "5E-1" for a base score of 0.5.
+func number(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, tracer.Maskf(invalidModelError, "%s", err)
+		}
+		return f, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, tracer.Maskf(invalidModelError, "%s", err)
+		}
+		return f, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	return 0, tracer.Maskf(invalidModelError, "expected number, got %T", val)
+}
+
+func numbers(val interface{}) ([]float64, error) {
+	lis, err := array(val)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	num := make([]float64, 0, len(lis))
+	for _, x := range lis {
+		n, err := number(x)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		num = append(num, n)
+	}
+
+	return num, nil
+}
+
+func object(val interface{}, key string) (map[string]interface{}, error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, tracer.Maskf(invalidModelError, "expected object, got %T", val)
+	}
+
+	sub, ok := obj[key].(map[string]interface{})
+	if !ok {
+		return nil, tracer.Maskf(invalidModelError, "expected object for key %q", key)
+	}
+
+	return sub, nil
+}
+
+func text(val interface{}) (string, error) {
+	str, ok := val.(string)
+	if !ok {
+		return "", tracer.Maskf(invalidModelError, "expected string, got %T", val)
+	}
+
+	return str, nil
+}
@@ -0,0 +1,66 @@
+package booster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var canceledError = &tracer.Error{
+	Kind: "canceledError",
+}
+
+func IsCanceled(err error) bool {
+	return errors.Is(err, canceledError)
+}
+
+var deadlineExceededError = &tracer.Error{
+	Kind: "deadlineExceededError",
+}
+
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, deadlineExceededError)
+}
+
+var invalidConfigError = &tracer.Error{
+	Kind: "invalidConfigError",
+}
+
+func IsInvalidConfig(err error) bool {
+	return errors.Is(err, invalidConfigError)
+}
+
+var invalidInputError = &tracer.Error{
+	Kind: "invalidInputError",
+}
+
+func IsInvalidInput(err error) bool {
+	return errors.Is(err, invalidInputError)
+}
+
+var invalidModelError = &tracer.Error{
+	Kind: "invalidModelError",
+}
+
+func IsInvalidModel(err error) bool {
+	return errors.Is(err, invalidModelError)
+}
+
+var notRestoredError = &tracer.Error{
+	Kind: "notRestoredError",
+}
+
+func IsNotRestored(err error) bool {
+	return errors.Is(err, notRestoredError)
+}
+
+// ctxerr translates the error of the given, already finished context into
+// one of the typed errors of this package.
+func ctxerr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return tracer.Maskf(deadlineExceededError, "%s", ctx.Err())
+	}
+
+	return tracer.Maskf(canceledError, "%s", ctx.Err())
+}
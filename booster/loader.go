@@ -0,0 +1,386 @@
+package booster
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost"
+)
+
+// Loader implements the xgboost.Loader interface natively in Go. It restores
+// the same saved models as loader.Loader and computes the same predictions,
+// but without spawning any Python child process.
+//
+//     ldr := &booster.Loader{
+//         Buc: []string{ ... },
+//         Buf: []string{ ... },
+//         Pat: "/Users/xh3b4sd/dat/",
+//     }
+//
+type Loader struct {
+	// Buc is the required bucket list.
+	Buc []string
+	// Buf is the required list of buffer hashes for loading this ensemble.
+	Buf []string
+	// Ext is the optional file extension of the saved models, either "ubj" or
+	// "json". Defaults to "ubj".
+	Ext string
+	// Nor optionally configures how probabilities are normalized, which must
+	// be the same as for training. Defaults to xgboost.NormalizeThreshold.
+	// Restoring fails if Nor differs from the normalization recorded next to
+	// the saved models.
+	Nor xgboost.Normalize
+	// Out enables returning the raw prediction of every bucket model per
+	// buffer hash and bucket as part of detailed predictions.
+	Out bool
+	// Pat is the required data path containing all ensemble data.
+	//
+	//     $ tree -L 1 /Users/xh3b4sd/dat/
+	//     /Users/xh3b4sd/dat/
+	//     ├── 01f5d6a195c0e829bdaee3ba3103159b
+	//     ├── 2b26756ea93b780dcd4c0f2a2d97e21c
+	//     ├── ...
+	//     └── ensemble.ubj
+	//
+	Pat string
+
+	ens *Booster
+	mod map[string]map[string]*Booster
+	mut sync.RWMutex
+}
+
+// Execute returns no script, since the native Loader does not spawn any
+// child process.
+func (l *Loader) Execute() ([]byte, error) {
+	{
+		err := l.configs()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	return nil, nil
+}
+
+func (l *Loader) Restore() error {
+	return l.RestoreContext(context.Background())
+}
+
+// RestoreContext loads the bucket models of every buffer and the ensemble
+// model from the configured data path.
+//
+//     <Pat>/<Buf>/mod/<Buc>.ubj
+//     <Pat>/ensemble.ubj
+//
+func (l *Loader) RestoreContext(ctx context.Context) error {
+	var err error
+
+	{
+		err = l.configs()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	{
+		err = l.Nor.Check(l.norfilp()...)
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Loader.Nor is invalid, %s", err)
+		}
+	}
+
+	mod := map[string]map[string]*Booster{}
+	for _, buf := range l.Buf {
+		mod[buf] = map[string]*Booster{}
+
+		for _, buc := range l.Buc {
+			if ctx.Err() != nil {
+				return ctxerr(ctx)
+			}
+
+			mod[buf][buc], err = Load(filepath.Join(l.Pat, buf, "mod", buc+"."+l.Ext))
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	}
+
+	var ens *Booster
+	{
+		ens, err = Load(filepath.Join(l.Pat, "ensemble."+l.Ext))
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	{
+		l.mut.Lock()
+		l.ens = ens
+		l.mod = mod
+		l.mut.Unlock()
+	}
+
+	return nil
+}
+
+func (l *Loader) Predict(inp map[string][]float32) (float32, error) {
+	return l.PredictContext(context.Background(), inp)
+}
+
+func (l *Loader) PredictContext(ctx context.Context, inp map[string][]float32) (float32, error) {
+	pre, err := l.PredictBatchContext(ctx, []map[string][]float32{inp})
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return pre[0], nil
+}
+
+func (l *Loader) PredictBatch(inp []map[string][]float32) ([]float32, error) {
+	return l.PredictBatchContext(context.Background(), inp)
+}
+
+func (l *Loader) PredictBatchContext(ctx context.Context, inp []map[string][]float32) ([]float32, error) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	if l.ens == nil {
+		return nil, tracer.Maskf(notRestoredError, "Loader.Restore must be called first")
+	}
+
+	var pre []float32
+	for _, x := range inp {
+		if ctx.Err() != nil {
+			return nil, ctxerr(ctx)
+		}
+
+		p, err := l.predict(x)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		pre = append(pre, p)
+	}
+
+	return pre, nil
+}
+
+func (l *Loader) PredictDetail(inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	return l.PredictDetailContext(context.Background(), inp)
+}
+
+func (l *Loader) PredictDetailContext(ctx context.Context, inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	if l.ens == nil {
+		return nil, tracer.Maskf(notRestoredError, "Loader.Restore must be called first")
+	}
+
+	var pre []xgboost.Prediction
+	for _, x := range inp {
+		if ctx.Err() != nil {
+			return nil, ctxerr(ctx)
+		}
+
+		p, err := l.detail(x)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		pre = append(pre, p)
+	}
+
+	return pre, nil
+}
+
+// Sigkill releases the restored models. No predictions can be made anymore
+// after calling Sigkill.
+func (l *Loader) Sigkill() error {
+	l.mut.Lock()
+	l.ens = nil
+	l.mod = nil
+	l.mut.Unlock()
+
+	return nil
+}
+
+// Validate returns an invalid config error if any of the required fields is
+// not set, or if any of the nested configurations is invalid.
+func (l *Loader) Validate() error {
+	if len(l.Buc) == 0 {
+		return tracer.Maskf(invalidConfigError, "Loader.Buc must not be empty")
+	}
+
+	if len(l.Buf) == 0 {
+		return tracer.Maskf(invalidConfigError, "Loader.Buf must not be empty")
+	}
+
+	if l.Ext != "" && l.Ext != "json" && l.Ext != "ubj" {
+		return tracer.Maskf(invalidConfigError, "Loader.Ext must be json or ubj")
+	}
+
+	if l.Pat == "" {
+		return tracer.Maskf(invalidConfigError, "Loader.Pat must not be empty")
+	}
+
+	{
+		err := l.Nor.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Loader.Nor is invalid, %s", err)
+		}
+	}
+
+	return nil
+}
+
+// buffer returns the sorted buffer hashes, which define the order in which
+// the bucket model predictions are fed into the ensemble.
+func (l *Loader) buffer() []string {
+	buf := append([]string{}, l.Buf...)
+	sort.Strings(buf)
+	return buf
+}
+
+func (l *Loader) configs() error {
+	{
+		err := l.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if l.Ext == "" {
+		l.Ext = "ubj"
+	}
+
+	return nil
+}
+
+// features computes the ensemble features for a single input, mirroring the
+// Python loader template. The first value of every feature vector is dropped,
+// since it is reserved for the label. The normalized bucket model predictions
+// of every buffer are the features fed into the ensemble. Multiclass bucket
+// models contribute their predictions unnormalized, one feature per predicted
+// value. The raw bucket model predictions are collected into the given map, if
+// any, where multiclass probabilities are reduced to the most probable class.
+func (l *Loader) features(inp map[string][]float32, out map[string]map[string]float32) ([]float32, error) {
+	var fea []float32
+
+	for _, buf := range l.buffer() {
+		vec, ok := inp[buf]
+		if !ok || len(vec) == 0 {
+			return nil, tracer.Maskf(invalidInputError, "features for buffer %q must not be empty", buf)
+		}
+
+		for _, buc := range l.Buc {
+			mod := l.mod[buf][buc]
+			pre := mod.Predict(vec[1:])
+
+			if mod.Classes() != 0 {
+				fea = append(fea, pre...)
+			} else if len(pre) == 1 {
+				fea = append(fea, l.Nor.Apply(pre[0]))
+			} else {
+				return nil, tracer.Maskf(invalidModelError, "model %s/%s must predict a single value", buf, buc)
+			}
+
+			if out != nil {
+				if out[buf] == nil {
+					out[buf] = map[string]float32{}
+				}
+
+				if len(pre) == 1 {
+					out[buf][buc] = pre[0]
+				} else {
+					out[buf][buc] = float32(argmax(pre))
+				}
+			}
+		}
+	}
+
+	return fea, nil
+}
+
+// norfilp returns the files recording the normalization of the saved models.
+func (l *Loader) norfilp() []string {
+	fil := []string{filepath.Join(l.Pat, "ensemble.nor.json")}
+	for _, buf := range l.Buf {
+		fil = append(fil, filepath.Join(l.Pat, buf, "mod", "nor.json"))
+	}
+
+	return fil
+}
+
+// predict returns the ensemble prediction for a single input, rounded to 3
+// decimals, the same way the Python loader formats its responses. Multiclass
+// ensembles predict the most probable class.
+func (l *Loader) predict(inp map[string][]float32) (float32, error) {
+	var err error
+
+	var fea []float32
+	{
+		fea, err = l.features(inp, nil)
+		if err != nil {
+			return 0, tracer.Mask(err)
+		}
+	}
+
+	var pre []float32
+	{
+		pre = l.ens.Predict(fea)
+		if l.ens.Classes() != 0 && len(pre) != 1 {
+			pre = []float32{float32(argmax(pre))}
+		}
+		if len(pre) != 1 {
+			return 0, tracer.Maskf(invalidModelError, "ensemble must predict a single value")
+		}
+	}
+
+	var flo float64
+	{
+		flo, _ = strconv.ParseFloat(strconv.FormatFloat(float64(pre[0]), 'f', 3, 64), 32)
+	}
+
+	return float32(flo), nil
+}
+
+// detail returns the detailed ensemble prediction for a single input.
+func (l *Loader) detail(inp map[string][]float32) (xgboost.Prediction, error) {
+	var err error
+
+	var out map[string]map[string]float32
+	if l.Out {
+		out = map[string]map[string]float32{}
+	}
+
+	var fea []float32
+	{
+		fea, err = l.features(inp, out)
+		if err != nil {
+			return xgboost.Prediction{}, tracer.Mask(err)
+		}
+	}
+
+	var pre xgboost.Prediction
+	{
+		pre.Mar = l.ens.Margin(fea)
+		pre.Mod = out
+	}
+
+	if l.ens.Classes() != 0 {
+		pre.Pro = append([]float32{}, pre.Mar...)
+		softmax(pre.Pro)
+
+		pre.Lab = float32(argmax(pre.Pro))
+	} else {
+		pre.Pro = l.ens.Predict(fea)
+		pre.Lab = l.Nor.Apply(pre.Pro[0])
+	}
+
+	return pre, nil
+}
@@ -0,0 +1,147 @@
+package booster
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xh3b4sd/xgboost"
+)
+
+// Test_Booster_Loader_Multiclass stacks the class probabilities of a
+// multiclass bucket model as ensemble features. The bucket model predicts
+// 0.41232669, 0.25008878 and 0.33758454, which land in the leaves -0.1 and
+// 0.15 of the ensemble.
+func Test_Booster_Loader_Multiclass(t *testing.T) {
+	dir := t.TempDir()
+
+	{
+		err := os.MkdirAll(filepath.Join(dir, "f", "mod"), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copyfile(t, "multiclass.json", filepath.Join(dir, "f", "mod", "a.json"))
+	copyfile(t, "numeric.json", filepath.Join(dir, "ensemble.json"))
+
+	ldr := &Loader{
+		Buc: []string{"a"},
+		Buf: []string{"f"},
+		Ext: "json",
+		Nor: xgboost.Normalize{Pre: xgboost.NormalizeRaw},
+		Out: true,
+		Pat: dir,
+	}
+
+	{
+		err := ldr.Restore()
+		if err != nil {
+			t.Fatalf("expected restore to succeed, got %#v", err)
+		}
+	}
+
+	inp := map[string][]float32{"f": {9, 0}}
+
+	{
+		pre, err := ldr.Predict(inp)
+		if err != nil {
+			t.Fatalf("expected predict to succeed, got %#v", err)
+		}
+
+		if pre != 0.512 {
+			t.Fatalf("expected 0.512, got %f", pre)
+		}
+	}
+
+	{
+		pre, err := ldr.PredictDetail([]map[string][]float32{inp})
+		if err != nil {
+			t.Fatalf("expected predict detail to succeed, got %#v", err)
+		}
+
+		equal(t, pre[0].Mar, []float32{0.05})
+		equal(t, pre[0].Pro, []float32{0.5124974})
+
+		if math.Abs(float64(pre[0].Lab-0.5124974)) > 1e-6 {
+			t.Fatalf("expected label 0.5124974, got %f", pre[0].Lab)
+		}
+
+		if pre[0].Mod["f"]["a"] != 0 {
+			t.Fatalf("expected most probable class 0, got %f", pre[0].Mod["f"]["a"])
+		}
+	}
+
+	{
+		err := ioutil.WriteFile(filepath.Join(dir, "ensemble.nor.json"), []byte(`{"high":0.85,"low":0.15,"offset":0,"preset":"threshold","scale":0}`+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		err := ldr.Restore()
+		if !IsInvalidConfig(err) {
+			t.Fatalf("expected invalid config error for a different normalization, got %#v", err)
+		}
+	}
+}
+
+// Test_Booster_Loader_Multiclass_Ensemble predicts the most probable class of
+// a multiclass ensemble. The bucket model predicts 0.61063923 and 0.37754067,
+// which are normalized to 1 and 0, and land in the right and left leaves of
+// the ensemble.
+func Test_Booster_Loader_Multiclass_Ensemble(t *testing.T) {
+	dir := t.TempDir()
+
+	{
+		err := os.MkdirAll(filepath.Join(dir, "f", "mod"), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copyfile(t, "numeric.json", filepath.Join(dir, "f", "mod", "a.json"))
+	copyfile(t, "multiclass.json", filepath.Join(dir, "ensemble.json"))
+
+	ldr := &Loader{
+		Buc: []string{"a"},
+		Buf: []string{"f"},
+		Ext: "json",
+		Nor: xgboost.Normalize{Hig: 0.6, Low: 0.4},
+		Pat: dir,
+	}
+
+	{
+		err := ldr.Restore()
+		if err != nil {
+			t.Fatalf("expected restore to succeed, got %#v", err)
+		}
+	}
+
+	pre, err := ldr.PredictBatch([]map[string][]float32{
+		{"f": {9, 0.7, 5.0}},
+		{"f": {9, 0.2, -3.0}},
+	})
+	if err != nil {
+		t.Fatalf("expected predict to succeed, got %#v", err)
+	}
+
+	equal(t, pre, []float32{1, 0})
+}
+
+func copyfile(t *testing.T, nam string, dst string) {
+	t.Helper()
+
+	byt, err := ioutil.ReadFile(filepath.Join("testdata", nam))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(dst, byt, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+# generate.py trains small models with XGBoost and saves them in JSON and UBJ,
+# along with the margins and predictions XGBoost itself computes for a set of
+# feature vectors. Test_Booster_Xgboost compares the booster package against
+# these. Run it in this directory with the XGBoost version to test against.
+#
+#     python3 generate.py
+#
+# For every model <nam> the files <nam>.json, <nam>.ubj and <nam>.pre.json are
+# written, the latter holding the best iteration, or -1 without early
+# stopping, the feature vectors, using null for missing values, and the
+# outputs of Booster.predict with output_margin=True and without.
+
+import json
+
+import numpy as np
+import xgboost as xgb
+
+rng = np.random.default_rng(42)
+
+
+def missing(fea, fra):
+  fea = fea.copy()
+  fea[rng.random(fea.shape) < fra] = np.nan
+  return fea
+
+
+def save(nam, bst, fea, typ=None):
+  bst.save_model(nam + ".json")
+  bst.save_model(nam + ".ubj")
+
+  bes = bst.attr("best_iteration")
+  if bes is None:
+    bes = -1
+    ite = (0, 0)
+  else:
+    bes = int(bes)
+    ite = (0, bes + 1)
+
+  dma = xgb.DMatrix(fea, missing=np.nan, feature_types=typ, enable_categorical=typ is not None)
+  mar = bst.predict(dma, output_margin=True, iteration_range=ite)
+  pre = bst.predict(dma, iteration_range=ite)
+
+  with open(nam + ".pre.json", "w") as f:
+    json.dump({
+      "bes": bes,
+      "fea": [[None if np.isnan(v) else float(v) for v in r] for r in fea],
+      "mar": np.asarray(mar, dtype=float).reshape(len(fea), -1).tolist(),
+      "pre": np.asarray(pre, dtype=float).reshape(len(fea), -1).tolist(),
+      "ver": xgb.__version__,
+    }, f, indent=2)
+    f.write("\n")
+
+
+def numeric():
+  fea = missing(rng.normal(size=(500, 4)), 0.1)
+  lab = (np.nan_to_num(fea[:, 0]) + np.nan_to_num(fea[:, 1]) * 0.5 > 0).astype(float)
+
+  bst = xgb.train(
+    {"objective": "binary:logistic", "max_depth": 3, "seed": 42},
+    xgb.DMatrix(fea, lab, missing=np.nan),
+    num_boost_round=10,
+  )
+
+  save("numeric", bst, missing(rng.normal(size=(50, 4)), 0.1))
+
+
+def categorical():
+  def features(n):
+    return missing(np.column_stack([rng.integers(0, 6, n), rng.normal(size=n)]).astype(float), 0.1)
+
+  fea = features(500)
+  lab = np.isin(fea[:, 0], [1, 3, 4]) * 2.0 + np.nan_to_num(fea[:, 1])
+
+  bst = xgb.train(
+    {"objective": "reg:squarederror", "max_depth": 3, "max_cat_to_onehot": 1, "tree_method": "hist", "seed": 42},
+    xgb.DMatrix(fea, lab, missing=np.nan, feature_types=["c", "q"], enable_categorical=True),
+    num_boost_round=10,
+  )
+
+  tes = features(50)
+  # Categories beyond the trained set and negative categories, both of which
+  # go left.
+  tes[0, 0] = 100
+  tes[1, 0] = -1
+
+  save("categorical", bst, tes, typ=["c", "q"])
+
+
+def multiclass():
+  fea = missing(rng.normal(size=(600, 3)), 0.1)
+  lab = np.digitize(np.nan_to_num(fea[:, 0]) + np.nan_to_num(fea[:, 2]), [-0.5, 0.5]).astype(float)
+
+  bst = xgb.train(
+    {"objective": "multi:softprob", "num_class": 3, "max_depth": 3, "seed": 42},
+    xgb.DMatrix(fea, lab, missing=np.nan),
+    num_boost_round=10,
+  )
+
+  save("multiclass", bst, missing(rng.normal(size=(50, 3)), 0.1))
+
+
+def stopped():
+  # Noisy labels and a large learning rate overfit quickly, so that early
+  # stopping keeps a best iteration before the last tree.
+  fea = missing(rng.normal(size=(400, 4)), 0.1)
+  lab = (np.nan_to_num(fea[:, 0]) + rng.normal(scale=2.0, size=400) > 0).astype(float)
+
+  bst = xgb.train(
+    {"objective": "reg:logistic", "eta": 1.0, "max_depth": 6, "seed": 42},
+    xgb.DMatrix(fea[:300], lab[:300], missing=np.nan),
+    num_boost_round=100,
+    evals=[(xgb.DMatrix(fea[300:], lab[300:], missing=np.nan), "val")],
+    early_stopping_rounds=3,
+    verbose_eval=False,
+  )
+
+  save("stopped", bst, missing(rng.normal(size=(50, 4)), 0.1))
+
+
+numeric()
+categorical()
+multiclass()
+stopped()
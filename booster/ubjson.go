@@ -0,0 +1,307 @@
+package booster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// ubjson decodes a single value encoded in universal binary JSON as written
+// by the "save_model" Python API of XGBoost. The decoded value resembles the
+// generic representation of encoding/json, that is maps, slices, strings,
+// booleans, nil and numbers, where integers are decoded as int64 and floats
+// as float64. Lengths and counts are checked against the remaining input, so
+// that corrupt or truncated input cannot cause huge allocations.
+//
+//     https://ubjson.org/type-reference/
+//
+type ubjson struct {
+	rea *bytes.Reader
+}
+
+func (u *ubjson) decode() (interface{}, error) {
+	for {
+		mar, err := u.rea.ReadByte()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		// No-op markers carry no value and may be used as padding in between
+		// any other values.
+		if mar == 'N' {
+			continue
+		}
+
+		return u.marker(mar)
+	}
+}
+
+func (u *ubjson) marker(mar byte) (interface{}, error) {
+	switch mar {
+	case 'Z':
+		return nil, nil
+	case 'T':
+		return true, nil
+	case 'F':
+		return false, nil
+	case 'i', 'U', 'I', 'l', 'L':
+		return u.integer(mar)
+	case 'd':
+		var v float32
+		err := binary.Read(u.rea, binary.BigEndian, &v)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		return float64(v), nil
+	case 'D':
+		var v float64
+		err := binary.Read(u.rea, binary.BigEndian, &v)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		return v, nil
+	case 'C':
+		b, err := u.rea.ReadByte()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		return string([]byte{b}), nil
+	case 'S':
+		return u.str()
+	case 'H':
+		s, err := u.str()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		return json.Number(s), nil
+	case '[':
+		return u.array()
+	case '{':
+		return u.object()
+	}
+
+	return nil, tracer.Maskf(invalidModelError, "unknown ubjson marker %q", mar)
+}
+
+func (u *ubjson) integer(mar byte) (int64, error) {
+	var err error
+
+	switch mar {
+	case 'i':
+		var v int8
+		err = binary.Read(u.rea, binary.BigEndian, &v)
+		return int64(v), tracer.Mask(err)
+	case 'U':
+		var v uint8
+		err = binary.Read(u.rea, binary.BigEndian, &v)
+		return int64(v), tracer.Mask(err)
+	case 'I':
+		var v int16
+		err = binary.Read(u.rea, binary.BigEndian, &v)
+		return int64(v), tracer.Mask(err)
+	case 'l':
+		var v int32
+		err = binary.Read(u.rea, binary.BigEndian, &v)
+		return int64(v), tracer.Mask(err)
+	case 'L':
+		var v int64
+		err = binary.Read(u.rea, binary.BigEndian, &v)
+		return v, tracer.Mask(err)
+	}
+
+	return 0, tracer.Maskf(invalidModelError, "expected ubjson integer marker, got %q", mar)
+}
+
+func (u *ubjson) length() (int, error) {
+	mar, err := u.rea.ReadByte()
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	num, err := u.integer(mar)
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	if num < 0 {
+		return 0, tracer.Maskf(invalidModelError, "invalid ubjson length %d", num)
+	}
+
+	// Every string byte and every container value takes at least one byte of
+	// input. Typed containers of nulls or booleans, which XGBoost never
+	// writes, are held to the same limit.
+	if num > int64(u.rea.Len()) {
+		return 0, tracer.Maskf(invalidModelError, "ubjson length %d exceeds the remaining %d bytes", num, u.rea.Len())
+	}
+
+	return int(num), nil
+}
+
+// peek returns the next byte without consuming it.
+func (u *ubjson) peek() (byte, error) {
+	nxt, err := u.rea.ReadByte()
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	err = u.rea.UnreadByte()
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return nxt, nil
+}
+
+func (u *ubjson) str() (string, error) {
+	num, err := u.length()
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	byt := make([]byte, num)
+	_, err = io.ReadFull(u.rea, byt)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	return string(byt), nil
+}
+
+// header reads the optional type and count of an optimized container. A zero
+// type marker means that every value brings its own marker. A negative count
+// means that the container is terminated by the given end marker.
+func (u *ubjson) header() (byte, int, error) {
+	var typ byte
+	var cnt int = -1
+
+	nxt, err := u.peek()
+	if err != nil {
+		return 0, 0, tracer.Mask(err)
+	}
+
+	if nxt == '$' {
+		_, _ = u.rea.ReadByte()
+
+		typ, err = u.rea.ReadByte()
+		if err != nil {
+			return 0, 0, tracer.Mask(err)
+		}
+
+		nxt, err = u.peek()
+		if err != nil {
+			return 0, 0, tracer.Mask(err)
+		}
+
+		if nxt != '#' {
+			return 0, 0, tracer.Maskf(invalidModelError, "typed ubjson container without count")
+		}
+	}
+
+	if nxt == '#' {
+		_, _ = u.rea.ReadByte()
+
+		cnt, err = u.length()
+		if err != nil {
+			return 0, 0, tracer.Mask(err)
+		}
+	}
+
+	return typ, cnt, nil
+}
+
+func (u *ubjson) array() (interface{}, error) {
+	typ, cnt, err := u.header()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var lis []interface{}
+	if cnt >= 0 {
+		lis = make([]interface{}, 0, cnt)
+	}
+
+	for i := 0; cnt < 0 || i < cnt; i++ {
+		var val interface{}
+
+		if typ != 0 {
+			val, err = u.marker(typ)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		} else {
+			mar, err := u.rea.ReadByte()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			if cnt < 0 && mar == ']' {
+				break
+			}
+
+			if mar == 'N' {
+				i--
+				continue
+			}
+
+			val, err = u.marker(mar)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+
+		lis = append(lis, val)
+	}
+
+	return lis, nil
+}
+
+func (u *ubjson) object() (interface{}, error) {
+	typ, cnt, err := u.header()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	obj := map[string]interface{}{}
+
+	for i := 0; cnt < 0 || i < cnt; i++ {
+		if cnt < 0 {
+			nxt, err := u.peek()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			if nxt == '}' {
+				_, _ = u.rea.ReadByte()
+				break
+			}
+
+			if nxt == 'N' {
+				_, _ = u.rea.ReadByte()
+				i--
+				continue
+			}
+		}
+
+		key, err := u.str()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		var val interface{}
+		if typ != 0 {
+			val, err = u.marker(typ)
+		} else {
+			val, err = u.decode()
+		}
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		obj[key] = val
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,58 @@
+package booster
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func Test_Booster_Ubjson_Length(t *testing.T) {
+	testCases := []struct {
+		byt []byte
+	}{
+		// Case 0, where an object key claims 2 GiB.
+		{
+			byt: []byte{'{', 'l', 0x7f, 0xff, 0xff, 0xff, 'a'},
+		},
+		// Case 1, where a string claims 2 GiB.
+		{
+			byt: []byte{'{', 'i', 1, 'a', 'S', 'l', 0x7f, 0xff, 0xff, 0xff, 'b', '}'},
+		},
+		// Case 2, where a typed array claims 2 billion values.
+		{
+			byt: []byte{'{', 'i', 1, 'a', '[', '$', 'd', '#', 'l', 0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 0, '}'},
+		},
+		// Case 3, where an untyped array claims 2 billion values.
+		{
+			byt: []byte{'{', 'i', 1, 'a', '[', '#', 'l', 0x7f, 0xff, 0xff, 0xff, 'Z', '}'},
+		},
+		// Case 4, where a negative length is given.
+		{
+			byt: []byte{'{', 'i', 0xff, 'a', '}'},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			_, err := Parse(tc.byt)
+			if !IsInvalidModel(err) {
+				t.Fatalf("expected invalid model error, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_Booster_Ubjson_Truncated(t *testing.T) {
+	byt, err := ioutil.ReadFile(filepath.Join("testdata", "numeric.ubj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 2, 10, len(byt) / 2, len(byt) - 1} {
+		_, err := Parse(byt[:n])
+		if err == nil {
+			t.Fatalf("expected error for %d of %d bytes, got nil", n, len(byt))
+		}
+	}
+}
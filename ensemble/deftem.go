@@ -3,6 +3,7 @@ package ensemble
 const deftem = `
 import json
 import pathlib
+import time
 
 import numpy as np
 import pandas as pd
@@ -29,6 +30,40 @@ BUCKET = [
 
 ################################################################################
 
+LABELS = json.loads({{ .Lab }})
+
+CLASSES = {float(r): int(c) for r, c in LABELS["classes"]}
+
+################################################################################
+
+timings = {
+    "data": 0.0,
+    "ensemble": 0.0,
+    "models": 0.0,
+    "total": time.monotonic(),
+}
+
+################################################################################
+
+def best_iteration(mod):
+  bes = mod.attr("best_iteration")
+
+  if bes is None:
+    return mod.num_boosted_rounds() - 1
+
+  return int(bes)
+
+################################################################################
+
+def booster_result(mod, evl):
+  return {
+    "best_iteration": best_iteration(mod),
+    "train": evl.get("tra_mat", {}),
+    "validation": evl.get("val_mat", {}),
+  }
+
+################################################################################
+
 def build_ensemble_matrix(context, subset):
   l = {}
   p = []
@@ -40,34 +75,27 @@ def build_ensemble_matrix(context, subset):
     m = xgb.DMatrix(f, l)
 
     for buc in BUCKET:
-      pre = context[buf]["mod"][buc].predict(m, iteration_range=(0, context[buf]["mod"][buc].best_iteration + 1))
-      p.append(normalize(pre))
-
-  y_true = []
+      mod = context[buf]["mod"][buc]
+      pre = mod.predict(m, iteration_range=(0, best_iteration(mod) + 1))
+      p.extend(columns(pre, objective(mod)))
 
-  for y in l:
-    if y == 5:
-      y_true.append(0.5)
-    elif y < 5:
-      y_true.append(1.0)
-    elif y > 5:
-      y_true.append(0.0)
+  y_true = [label(y) for y in l]
 
   return xgb.DMatrix(pd.DataFrame(p).transpose(), pd.DataFrame(y_true))
 
 ################################################################################
 
+def columns(pre, obj):
+  if obj.startswith("multi:"):
+    pre = np.asarray(pre).reshape(len(pre), -1)
+    return [pre[:, i] for i in range(pre.shape[1])]
+
+  return [normalize(pre)]
+
+################################################################################
+
 def ensemble_params():
-  return {
-    "base_score": 0.50,
-    "booster": "gbtree",
-    "gamma": 10.00,
-    "grow_policy": "lossguide",
-    "learning_rate": 0.02,
-    "max_depth": 20,
-    "objective": "reg:logistic",
-    "eval_metric": ["error", "logloss"],
-  }
+  return json.loads({{ .Par }})
 
 ################################################################################
 
@@ -105,23 +133,110 @@ def load_model(p):
 
 ################################################################################
 
-def normalize(l):
-  l = np.where(l > 0.85, 1, l)
-  l = np.where(((l >= 0.15) & (l <= 0.85)), 0.5, l)
-  l = np.where(l < 0.15, 0, l)
+def label(y):
+  if LABELS["preset"] == "identity":
+    return float(y)
 
-  return l
+  if LABELS["preset"] == "binary":
+    return 1.0 if y > LABELS["threshold"] else 0.0
+
+  if LABELS["preset"] == "class-map":
+    if float(y) not in CLASSES:
+      raise ValueError("label " + str(y) + " not contained in class map")
+    return float(CLASSES[float(y)])
+
+  if y == LABELS["middle"]:
+    return 0.5
+  elif y < LABELS["middle"]:
+    return 1.0
+  else:
+    return 0.0
 
 ################################################################################
 
-def train_model(params, tra_mat, val_mat, evl_res=None, xgb_mod=None):
+def objective(mod):
+  return json.loads(mod.save_config())["learner"]["objective"]["name"]
+
+################################################################################
+
+def predicted(pre, obj):
+  if obj.startswith("multi:"):
+    pre = np.asarray(pre).reshape(len(pre), -1)
+    if pre.shape[1] == 1:
+      return pre[:, 0]
+    return pre.argmax(axis=1).astype(float)
+
+  return normalize(pre)
+
+################################################################################
+
+{{ .Nor }}
+################################################################################
+{{ if .Pro }}
+def emit_progress(event):
+  print("XGBOOST-PROGRESS " + json.dumps(event), flush=True)
+
+################################################################################
+
+class Progress(xgb.callback.TrainingCallback):
+  def __init__(self, name, rounds):
+    self.count = 0
+    self.last = -1
+    self.name = name
+    self.rounds = rounds
+
+  def before_training(self, model):
+    emit_progress({"booster": self.name, "kind": "start"})
+
+    return model
+
+  def after_iteration(self, model, epoch, evals_log):
+    self.count += 1
+    self.last = epoch
+
+    emit_progress({
+      "booster": self.name,
+      "kind": "round",
+      "round": epoch,
+      "train": {k: float(v[-1]) for k, v in evals_log.get("tra_mat", {}).items()},
+      "validation": {k: float(v[-1]) for k, v in evals_log.get("val_mat", {}).items()},
+    })
+
+    return False
+
+  def after_training(self, model):
+    bes = best_iteration(model)
+
+    if self.count < self.rounds:
+      emit_progress({"best_iteration": bes, "booster": self.name, "kind": "stop", "round": self.last})
+
+    emit_progress({"best_iteration": bes, "booster": self.name, "kind": "done", "round": self.last})
+
+    return model
+
+################################################################################
+{{ end }}
+def train_model(params, tra_mat, val_mat, name, evl_res=None, xgb_mod=None):
+  params = dict(params)
+
+  rounds = params.pop("num_boost_round", 5000)
+  early = params.pop("early_stopping_rounds", 0)
+
+  if str(params.get("objective", "")).startswith("multi:"):
+    params.setdefault("num_class", len(set(CLASSES.values())))
+
+  callbacks = []
+{{- if .Pro }}
+  callbacks.append(Progress(name, rounds))
+{{- end }}
+  if early:
+    callbacks.append(xgb.callback.EarlyStopping(rounds=early))
+
   return xgb.train(
     params,
     tra_mat,
-    num_boost_round=5000,
-    callbacks=[
-        xgb.callback.EarlyStopping(rounds=25),
-    ],
+    num_boost_round=rounds,
+    callbacks=callbacks,
     evals=[(tra_mat, 'tra_mat'), (val_mat, 'val_mat')],
     evals_result=evl_res,
     verbose_eval=100,
@@ -134,6 +249,8 @@ context = {}
 
 ################################################################################
 
+sta = time.monotonic()
+
 context = fill_ens(context)
 context = fill_mod(context)
 
@@ -143,25 +260,34 @@ tra_mat = build_ensemble_matrix(context, "tra")
 tes_mat = build_ensemble_matrix(context, "tes")
 val_mat = build_ensemble_matrix(context, "val")
 
+timings["data"] += time.monotonic() - sta
+
 ################################################################################
 
+sta = time.monotonic()
+
+evl = {}
 ensemble = train_model(
   ensemble_params(),
   tra_mat,
   val_mat,
+  "ensemble",
+  evl_res=evl,
 {{- if .Upd }}
   xgb_mod="{{ .Pat }}" + "/ensemble.ubj",
 {{- end }}
 )
 
+timings["ensemble"] += time.monotonic() - sta
+
 ################################################################################
 
-pre_mat = ensemble.predict(tes_mat, iteration_range=(0, ensemble.best_iteration + 1))
+pre_mat = ensemble.predict(tes_mat, iteration_range=(0, best_iteration(ensemble) + 1))
 
 ################################################################################
 
 y_true = tes_mat.get_label()
-y_pred = normalize(pre_mat)
+y_pred = predicted(pre_mat, objective(ensemble))
 
 ################################################################################
 
@@ -172,9 +298,24 @@ print("log_err:", log_err)
 
 ensemble.save_model("{{ .Pat }}" + "/ensemble.ubj")
 
+with open("{{ .Pat }}" + "/ensemble.par.json", 'w') as the_file:
+    the_file.write(json.dumps(ensemble_params()) + '\n')
+
+with open("{{ .Pat }}" + "/ensemble.nor.json", 'w') as the_file:
+    the_file.write({{ .Nom }} + '\n')
+
 ################################################################################
 
+timings["total"] = time.monotonic() - timings["total"]
+
+result = {
+  "ensemble": booster_result(ensemble, evl),
+  "log_err": log_err.astype(float),
+  "saved": True,
+  "timings": timings,
+}
+
 pathlib.Path("{{ .Pat }}" + "/res/").mkdir(exist_ok=True)
 with open("{{ .Pat }}" + "/res/res.json", 'w') as the_file:
-    the_file.write(json.dumps({"log_err": log_err.astype(float)}) + '\n')
+    the_file.write(json.dumps(result) + '\n')
 `
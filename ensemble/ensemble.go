@@ -1,15 +1,18 @@
 package ensemble
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost"
+	"github.com/xh3b4sd/xgboost/python"
+	"github.com/xh3b4sd/xgboost/runner"
 )
 
 type Ensemble struct {
@@ -20,6 +23,23 @@ type Ensemble struct {
 	Cmd *exec.Cmd
 	Deb bool
 	Fil *os.File
+	// Hoo contains optional hooks for capturing the output of the child
+	// process, classifying its exit and retaining its temp file.
+	Hoo runner.Hooks
+	// Lab optionally configures how raw labels are transformed into the
+	// targets the ensemble is trained and evaluated on. Defaults to
+	// xgboost.LabelThreeWay.
+	Lab xgboost.Labels
+	// Nor optionally configures how probabilities are normalized, which must
+	// be the same for training and serving. Defaults to
+	// xgboost.NormalizeThreshold. The normalization is recorded in
+	// "<Pat>/ensemble.nor.json" whenever the ensemble gets saved.
+	Nor xgboost.Normalize
+	// Par optionally configures the hyperparameters of the ensemble. Zero
+	// values fall back to the defaults of the default template. The effective
+	// parameters are recorded in "<Pat>/ensemble.par.json" whenever the
+	// ensemble gets saved.
+	Par xgboost.Params
 	// Pat is the required data path in which the data of the trained model will
 	// be put in.
 	//
@@ -38,163 +58,303 @@ type Ensemble struct {
 	//     └── ensemble.ubj
 	//
 	Pat string
+	// Pde enables the parent death signal on Linux, which kills the child
+	// process once the current process exits, even if the current process
+	// got killed itself without any chance to clean up.
+	Pde bool
+	// Pro is optionally called with every progress event while training, e.g.
+	// after every boosting round. Pro is called sequentially from a separate
+	// goroutine.
+	//
+	//     Pro: func(pro xgboost.Progress) {
+	//         if pro.Kin == xgboost.ProgressRound {
+	//             fmt.Printf("%s round %d logloss %f\n", pro.Boo, pro.Rou, pro.Val["logloss"])
+	//         }
+	//     },
+	//
+	Pro func(xgboost.Progress)
+	// Pyt optionally configures the Python interpreter, its environment and
+	// the resource limits of the child process.
+	Pyt python.Config
+	// Res is the result of the last successful training run, as read from
+	// res.json once Train succeeded.
+	Res *xgboost.TrainResult
 	// Tem is the required Python script template that is first being rendered
 	// and persisted, and then executed in a child process.
 	Tem string
 	// Upd requires an ensemble to exist in order for it to continue training on
 	// the prepared data set.
 	Upd bool
+	// Wai enables waiting for another process to release its lock on Pat,
+	// instead of failing right away with an in use error.
+	Wai bool
 }
 
 func (e *Ensemble) Execute() ([]byte, error) {
 	{
-		e.configs()
-	}
-
-	var buf bytes.Buffer
-	{
-		t, err := template.New("ensemble").Parse(e.Tem)
+		err := e.configs()
 		if err != nil {
 			return nil, tracer.Mask(err)
 		}
+	}
 
-		err = t.Execute(&buf, e.mapping())
-		if err != nil {
-			return nil, tracer.Mask(err)
-		}
+	byt, err := runner.Render("ensemble", e.Tem, e.mapping())
+	if err != nil {
+		return nil, tracer.Mask(err)
 	}
 
-	return buf.Bytes(), nil
+	return byt, nil
 }
 
 func (e *Ensemble) Train() error {
-	var err error
+	return e.TrainContext(context.Background())
+}
 
+// TrainContext works like Train but kills the child process group once the
+// given context gets canceled, in which case a deadline or cancelation error
+// is returned.
+func (e *Ensemble) TrainContext(ctx context.Context) (err error) {
 	{
-		e.configs()
-		e.cleanup()
+		err = e.configs()
+		if err != nil {
+			return tracer.Mask(err)
+		}
 	}
 
-	var byt []byte
 	{
-		byt, err = e.Execute()
+		err = e.Pyt.Check(ctx, python.Training)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	// Other processes training with the same Pat are locked out, so that
+	// nobody removes the temp files of anybody else.
+	var loc *os.File
+	{
+		loc, err = runner.Lock(ctx, e.locfilp(), e.Wai)
+		if err != nil && ctx.Err() != nil {
+			return ctxerr(ctx)
+		} else if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	defer runner.Unlock(loc)
+
 	{
-		e.Fil, err = os.CreateTemp("", "xgboost-ensemble-template-*")
+		err = e.cleanup()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	// The temp files of the child process are cleaned up once training is
+	// over, no matter whether the child process succeeded or not.
+	defer func() {
+		cle := e.cleanup()
+		if err == nil && cle != nil {
+			err = tracer.Mask(cle)
+		}
+	}()
+
+	var byt []byte
 	{
-		_, err := e.Fil.Write(byt)
+		byt, err = e.Execute()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	var chi *runner.Child
 	{
-		err := e.Fil.Close()
+		chi, err = e.runner().Start(byt)
 		if err != nil {
 			return tracer.Mask(err)
 		}
+
+		e.Cmd = chi.Cmd
+		e.Fil = chi.Fil
+	}
+
+	select {
+	case <-chi.Done():
+		err := chi.Err()
+		if err != nil {
+			return tracer.Maskf(childExitedError, "%s", err)
+		}
+	case <-ctx.Done():
+		{
+			err := chi.Kill()
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		{
+			<-chi.Done()
+		}
+
+		return ctxerr(ctx)
 	}
 
 	{
-		err = ioutil.WriteFile(e.temfilp(), e.temfilb(), 0664)
+		e.Res, err = e.Result()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	return nil
+}
+
+// Result returns the result of the last training run as written to res.json
+// within Pat, regardless of whether the training run happened within the
+// current process.
+func (e *Ensemble) Result() (*xgboost.TrainResult, error) {
 	{
-		e.Cmd = exec.Command("python3", e.Fil.Name())
+		err := e.configs()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
 	}
 
-	if e.Deb {
-		e.Cmd.Stdout = os.Stdout
-		e.Cmd.Stderr = os.Stderr
+	byt, err := ioutil.ReadFile(e.resfilp())
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var res xgboost.TrainResult
+	err = json.Unmarshal(byt, &res)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return &res, nil
+}
+
+// Validate returns an invalid config error if any of the required fields is
+// not set, or if any of the nested configurations is invalid.
+func (e *Ensemble) Validate() error {
+	if len(e.Buc) == 0 {
+		return tracer.Maskf(invalidConfigError, "Ensemble.Buc must not be empty")
+	}
+
+	if len(e.Buf) == 0 {
+		return tracer.Maskf(invalidConfigError, "Ensemble.Buf must not be empty")
+	}
+
+	if e.Pat == "" {
+		return tracer.Maskf(invalidConfigError, "Ensemble.Pat must not be empty")
 	}
 
 	{
-		err := e.Cmd.Start()
+		err := e.Lab.Validate()
 		if err != nil {
-			return tracer.Mask(err)
+			return tracer.Maskf(invalidConfigError, "Ensemble.Lab is invalid, %s", err)
 		}
 	}
 
 	{
-		err := e.Cmd.Wait()
+		err := e.Nor.Validate()
 		if err != nil {
-			return tracer.Mask(err)
+			return tracer.Maskf(invalidConfigError, "Ensemble.Nor is invalid, %s", err)
 		}
 	}
 
 	{
-		e.cleanup()
+		err := e.Par.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Ensemble.Par is invalid, %s", err)
+		}
 	}
 
-	return nil
-}
-
-func (e *Ensemble) cleanup() {
-	if exists(e.temfilp()) {
-		if exists(e.temfilc()) {
-			err := os.Remove(e.temfilc())
-			if err != nil {
-				panic(err)
-			}
+	{
+		err := e.Lab.ValidateObjective(e.Par.Fill(defens).Objective())
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Ensemble.Par is invalid for Ensemble.Lab, %s", err)
 		}
+	}
 
-		err := os.Remove(e.temfilp())
+	{
+		err := e.Pyt.Validate()
 		if err != nil {
-			panic(err)
+			return tracer.Maskf(invalidConfigError, "Ensemble.Pyt is invalid, %s", err)
 		}
 	}
+
+	return nil
 }
 
-func (e *Ensemble) configs() {
-	if len(e.Buc) == 0 {
-		panic("Ensemble.Buc must not be empty")
+func (e *Ensemble) cleanup() error {
+	err := e.runner().Cleanup()
+	if err != nil {
+		return tracer.Mask(err)
 	}
 
-	if len(e.Buf) == 0 {
-		panic("Ensemble.Buf must not be empty")
-	}
+	return nil
+}
 
-	if e.Pat == "" {
-		panic("Ensemble.Pat must not be empty")
+func (e *Ensemble) configs() error {
+	{
+		err := e.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
 	}
 
 	if e.Tem == "" {
 		e.Tem = deftem
 	}
+
+	return nil
+}
+
+func (e *Ensemble) locfilp() string {
+	return filepath.Join(e.Pat, "ensemble.lock")
 }
 
 func (e *Ensemble) mapping() map[string]interface{} {
 	return map[string]interface{}{
 		"Buc": e.Buc,
 		"Buf": e.Buf,
+		"Lab": runner.Literal(e.Lab.Map()),
+		"Nom": runner.Literal(e.Nor.Map()),
+		"Nor": e.Nor.Script(),
+		"Par": runner.Literal(e.Par.Fill(defens).Map()),
 		"Pat": strings.TrimSuffix(e.Pat, "/"),
+		"Pro": e.Pro != nil,
 		"Upd": e.Upd,
 	}
 }
 
-func (e *Ensemble) temfilb() []byte {
-	return []byte(e.Fil.Name())
+func (e *Ensemble) resfilp() string {
+	return filepath.Join(e.Pat, "res", "res.json")
 }
 
-func (e *Ensemble) temfilc() string {
-	byt, err := ioutil.ReadFile(e.temfilp())
-	if err != nil {
-		panic(err)
+func (e *Ensemble) runner() runner.Runner {
+	hoo := e.Hoo
+	if e.Pro != nil {
+		lin := hoo.Lin
+		hoo.Lin = func(s string) bool {
+			pro, ok := xgboost.ParseProgress(s)
+			if ok {
+				e.Pro(pro)
+				return true
+			}
+
+			return lin != nil && lin(s)
+		}
 	}
 
-	return strings.TrimSpace(string(byt))
+	return runner.Runner{
+		Deb: e.Deb,
+		Hoo: hoo,
+		Mar: e.temfilp(),
+		Nam: "ensemble",
+		Pde: e.Pde,
+		Pyt: e.Pyt,
+	}
 }
 
 func (e *Ensemble) temfilp() string {
@@ -0,0 +1,17 @@
+package ensemble
+
+import "github.com/xh3b4sd/xgboost"
+
+// defens are the default parameters of the ensemble.
+var defens = xgboost.Params{
+	Bas: 0.50,
+	Boo: "gbtree",
+	Ear: 25,
+	Eva: []string{"error", "logloss"},
+	Gam: 10.00,
+	Gro: "lossguide",
+	Lea: 0.02,
+	Max: 20,
+	Obj: "reg:logistic",
+	Rou: 5000,
+}
@@ -0,0 +1,31 @@
+package xgboost
+
+import (
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var invalidLabelsError = &tracer.Error{
+	Kind: "invalidLabelsError",
+}
+
+func IsInvalidLabels(err error) bool {
+	return errors.Is(err, invalidLabelsError)
+}
+
+var invalidNormalizeError = &tracer.Error{
+	Kind: "invalidNormalizeError",
+}
+
+func IsInvalidNormalize(err error) bool {
+	return errors.Is(err, invalidNormalizeError)
+}
+
+var invalidParamsError = &tracer.Error{
+	Kind: "invalidParamsError",
+}
+
+func IsInvalidParams(err error) bool {
+	return errors.Is(err, invalidParamsError)
+}
@@ -0,0 +1,131 @@
+package xgboost
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	// LabelBinary maps raw labels above Labels.Thr to 1.0 and all other raw
+	// labels to 0.0.
+	LabelBinary = "binary"
+	// LabelClassMap maps raw labels to class indices according to Labels.Cla,
+	// and requires a multiclass objective like "multi:softprob".
+	LabelClassMap = "class-map"
+	// LabelIdentity uses raw labels as they are.
+	LabelIdentity = "identity"
+	// LabelThreeWay maps raw labels equal to Labels.Mid to 0.5, raw labels
+	// below Labels.Mid to 1.0 and raw labels above Labels.Mid to 0.0.
+	LabelThreeWay = "three-way"
+)
+
+// Labels describes how the raw labels of the data sets are transformed into
+// the targets ensembles are trained and evaluated on. Bucket models are
+// trained on the raw labels, except for LabelClassMap, whose multiclass
+// objectives require class indices. The zero value describes LabelThreeWay
+// around the raw label 5.
+//
+//     lab := xgboost.Labels{
+//         Pre: xgboost.LabelClassMap,
+//         Cla: map[float64]int{
+//             1: 0,
+//             2: 0,
+//             3: 1,
+//             4: 2,
+//         },
+//     }
+//
+type Labels struct {
+	// Cla maps raw labels to class indices for LabelClassMap. Multiple raw
+	// labels may map to the same class, but the class indices must cover all
+	// classes from 0 to n-1 without gaps. Raw labels not contained in Cla fail
+	// training.
+	Cla map[float64]int
+	// Mid is the raw label separating both halves for LabelThreeWay. Defaults
+	// to 5.
+	Mid float64
+	// Pre is the preset, e.g. LabelBinary. Defaults to LabelThreeWay.
+	Pre string
+	// Thr is the raw label threshold for LabelBinary.
+	Thr float64
+}
+
+// Map returns the label transformation as passed to the training scripts,
+// with all defaults applied.
+func (l Labels) Map() map[string]interface{} {
+	pre := l.Pre
+	if pre == "" {
+		pre = LabelThreeWay
+	}
+
+	mid := l.Mid
+	if mid == 0 {
+		mid = 5
+	}
+
+	var raw []float64
+	for k := range l.Cla {
+		raw = append(raw, k)
+	}
+	sort.Float64s(raw)
+
+	cla := [][2]float64{}
+	for _, r := range raw {
+		cla = append(cla, [2]float64{r, float64(l.Cla[r])})
+	}
+
+	return map[string]interface{}{
+		"classes":   cla,
+		"middle":    mid,
+		"preset":    pre,
+		"threshold": l.Thr,
+	}
+}
+
+// Validate returns an invalid labels error if the preset is unknown or the
+// class map is malformed.
+func (l Labels) Validate() error {
+	if l.Pre != "" && l.Pre != LabelBinary && l.Pre != LabelClassMap && l.Pre != LabelIdentity && l.Pre != LabelThreeWay {
+		return tracer.Maskf(invalidLabelsError, "Labels.Pre must be %s, %s, %s or %s, got %q", LabelBinary, LabelClassMap, LabelIdentity, LabelThreeWay, l.Pre)
+	}
+
+	if l.Pre == LabelClassMap && len(l.Cla) == 0 {
+		return tracer.Maskf(invalidLabelsError, "Labels.Cla must not be empty for %s", LabelClassMap)
+	}
+
+	cla := map[int]bool{}
+	for _, v := range l.Cla {
+		if v < 0 {
+			return tracer.Maskf(invalidLabelsError, "Labels.Cla must not contain negative class indices")
+		}
+
+		cla[v] = true
+	}
+
+	for i := 0; i < len(cla); i++ {
+		if !cla[i] {
+			return tracer.Maskf(invalidLabelsError, "Labels.Cla must contain all class indices from 0 to %d, missing %d", len(cla)-1, i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateObjective returns an invalid labels error if the preset does not fit
+// the given learning objective. LabelClassMap requires a multiclass objective
+// like "multi:softprob", and multiclass objectives require LabelClassMap.
+func (l Labels) ValidateObjective(obj string) error {
+	mul := strings.HasPrefix(obj, "multi:")
+
+	if l.Pre == LabelClassMap && !mul {
+		return tracer.Maskf(invalidLabelsError, "Labels.Pre %s requires a multiclass objective, got %q", LabelClassMap, obj)
+	}
+
+	if l.Pre != LabelClassMap && mul {
+		return tracer.Maskf(invalidLabelsError, "multiclass objective %q requires Labels.Pre %s", obj, LabelClassMap)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package xgboost
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_Labels_Map(t *testing.T) {
+	testCases := []struct {
+		lab Labels
+		exp map[string]interface{}
+	}{
+		// Case 0, where the zero value describes three-way labels around 5.
+		{
+			lab: Labels{},
+			exp: map[string]interface{}{
+				"classes":   [][2]float64{},
+				"middle":    5.0,
+				"preset":    LabelThreeWay,
+				"threshold": 0.0,
+			},
+		},
+		// Case 1
+		{
+			lab: Labels{Pre: LabelBinary, Thr: 0.5},
+			exp: map[string]interface{}{
+				"classes":   [][2]float64{},
+				"middle":    5.0,
+				"preset":    LabelBinary,
+				"threshold": 0.5,
+			},
+		},
+		// Case 2, where classes are sorted by raw label.
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: map[float64]int{4: 2, 1: 0, 3: 1, 2: 0}},
+			exp: map[string]interface{}{
+				"classes":   [][2]float64{{1, 0}, {2, 0}, {3, 1}, {4, 2}},
+				"middle":    5.0,
+				"preset":    LabelClassMap,
+				"threshold": 0.0,
+			},
+		},
+		// Case 3
+		{
+			lab: Labels{Mid: 3},
+			exp: map[string]interface{}{
+				"classes":   [][2]float64{},
+				"middle":    3.0,
+				"preset":    LabelThreeWay,
+				"threshold": 0.0,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			lab := tc.lab.Map()
+			if !reflect.DeepEqual(lab, tc.exp) {
+				t.Fatalf("expected %#v, got %#v", tc.exp, lab)
+			}
+		})
+	}
+}
+
+func Test_Labels_Validate(t *testing.T) {
+	testCases := []struct {
+		lab Labels
+		inv bool
+	}{
+		// Case 0
+		{
+			lab: Labels{},
+			inv: false,
+		},
+		// Case 1
+		{
+			lab: Labels{Pre: LabelIdentity},
+			inv: false,
+		},
+		// Case 2
+		{
+			lab: Labels{Pre: "one-hot"},
+			inv: true,
+		},
+		// Case 3, where multiple raw labels map to the same class.
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: map[float64]int{1: 0, 2: 0, 3: 1}},
+			inv: false,
+		},
+		// Case 4
+		{
+			lab: Labels{Pre: LabelClassMap},
+			inv: true,
+		},
+		// Case 5, where class 1 is missing.
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: map[float64]int{1: 0, 2: 2}},
+			inv: true,
+		},
+		// Case 6, where class 0 is missing.
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: map[float64]int{1: 1, 2: 2}},
+			inv: true,
+		},
+		// Case 7
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: map[float64]int{1: -1, 2: 0}},
+			inv: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := tc.lab.Validate()
+			if IsInvalidLabels(err) != tc.inv {
+				t.Fatalf("expected invalid labels error to be %t, got %#v", tc.inv, err)
+			}
+		})
+	}
+}
+
+func Test_Labels_ValidateObjective(t *testing.T) {
+	cla := map[float64]int{1: 0, 2: 1, 3: 2}
+
+	testCases := []struct {
+		lab Labels
+		obj string
+		inv bool
+	}{
+		// Case 0
+		{
+			lab: Labels{},
+			obj: "reg:logistic",
+			inv: false,
+		},
+		// Case 1, where the default objective of the training scripts is used.
+		{
+			lab: Labels{Pre: LabelBinary},
+			obj: "",
+			inv: false,
+		},
+		// Case 2
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: cla},
+			obj: "multi:softprob",
+			inv: false,
+		},
+		// Case 3
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: cla},
+			obj: "multi:softmax",
+			inv: false,
+		},
+		// Case 4, where class indices do not fit a binary objective.
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: cla},
+			obj: "binary:logistic",
+			inv: true,
+		},
+		// Case 5
+		{
+			lab: Labels{Pre: LabelClassMap, Cla: cla},
+			obj: "",
+			inv: true,
+		},
+		// Case 6, where a multiclass objective lacks class indices.
+		{
+			lab: Labels{},
+			obj: "multi:softprob",
+			inv: true,
+		},
+		// Case 7
+		{
+			lab: Labels{Pre: LabelIdentity},
+			obj: "multi:softmax",
+			inv: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := tc.lab.ValidateObjective(tc.obj)
+			if IsInvalidLabels(err) != tc.inv {
+				t.Fatalf("expected invalid labels error to be %t, got %#v", tc.inv, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,15 @@ package loader
 
 const deftem = `
 import json
+import os
+import signal
+import socket
+import socketserver
+import struct
+import sys
+import threading
+import traceback
+import urllib.parse
 
 import numpy as np
 import pandas as pd
@@ -10,7 +19,15 @@ import xgboost as xgb
 from http.server import BaseHTTPRequestHandler, HTTPServer
 
 ################################################################################
+{{ if .Pip }}
+# The original stdout is reserved for the pipe protocol. Anything else written
+# to stdout, even by native libraries, is redirected to stderr.
+PIPE = os.fdopen(os.dup(1), "wb")
+os.dup2(2, 1)
+sys.stdout = sys.stderr
 
+################################################################################
+{{ end }}
 BUFFER = [
 {{- range $b := .Buf }}
     "{{ $b }}",
@@ -29,23 +46,69 @@ BUCKET = [
 
 ################################################################################
 
-def build_ensemble_matrix(context):
+NONCE = "{{ .Non }}"
+
+################################################################################
+
+def best_iteration(mod):
+  bes = mod.attr("best_iteration")
+
+  if bes is None:
+    return mod.num_boosted_rounds() - 1
+
+  return int(bes)
+
+################################################################################
+
+def build_ensemble_matrix(context, out=None):
   p = []
 
   for buf in BUFFER:
     m = xgb.DMatrix(context[buf]["ens"])
 
     for buc in BUCKET:
-      pre = context[buf]["mod"][buc].predict(m, iteration_range=(0, context[buf]["mod"][buc].best_iteration + 1))
-      p.append(normalize(pre))
+      mod = context[buf]["mod"][buc]
+      pre = mod.predict(m, iteration_range=(0, best_iteration(mod) + 1))
+      p.extend(columns(pre, context[buf]["obj"][buc]))
+
+      if out is not None:
+        out.setdefault(buf, {})[buc] = pre
 
   return xgb.DMatrix(pd.DataFrame(p).transpose())
 
 ################################################################################
 
+def columns(pre, obj):
+  if obj.startswith("multi:"):
+    pre = np.asarray(pre).reshape(len(pre), -1)
+    return [pre[:, i] for i in range(pre.shape[1])]
+
+  return [normalize(pre)]
+
+################################################################################
+
+def decode_binary(body):
+  row, num = struct.unpack_from("<II", body, 0)
+  if num != len(BUFFER):
+    raise ValueError(f'expected {len(BUFFER)} buffers, got {num}')
+
+  siz = struct.unpack_from("<" + "I" * num, body, 8)
+  mat = np.frombuffer(body, dtype="<f4", offset=8 + 4 * num).reshape(row, sum(siz))
+
+  inp = [{} for _ in range(row)]
+  col = 0
+  for buf, s in zip(BUFFER, siz):
+    for i in range(row):
+      inp[i][buf] = mat[i, col:col + s]
+    col += s
+
+  return inp
+
+################################################################################
+
 def fill_ens(context, input):
   for buf in BUFFER:
-    f = pd.DataFrame([input[buf]]).copy()
+    f = pd.DataFrame([i[buf] for i in input]).copy()
     f.pop(0)
     context[buf]["ens"] = f.astype('float')
 
@@ -57,17 +120,51 @@ def fill_mod(context):
   for buf in BUFFER:
     context[buf] = {
         "mod": {},
+        "obj": {},
     }
 
     for buc in BUCKET:
       context[buf]["mod"][buc] = load_model("{{ .Pat }}" + "/" + buf + "/mod/" + buc + ".ubj")
+      context[buf]["obj"][buc] = objective(context[buf]["mod"][buc])
 
   context["ens"] = load_model("{{ .Pat }}/ensemble.ubj")
+  context["obj"] = objective(context["ens"])
 
   return context
 
 ################################################################################
 
+def handle(path, con_typ, body):
+  url = urllib.parse.urlsplit(path)
+  que = urllib.parse.parse_qs(url.query)
+
+  if con_typ == "application/octet-stream":
+    req_bod = decode_binary(body)
+  else:
+    req_bod = json.loads(body.decode('utf-8'))
+
+  if url.path == "/explain":
+    return 'application/json', json.dumps(predict_explain(req_bod)).encode()
+
+  if url.path == "/leaves":
+    return 'application/json', json.dumps(predict_leaves(req_bod)).encode()
+
+  if url.path == "/detail":
+    return 'application/json', json.dumps(predict_detail(req_bod, que.get("out") == ["true"])).encode()
+
+  if con_typ == "application/octet-stream":
+    pre_mat = predict(req_bod)
+    return 'application/octet-stream', np.asarray(pre_mat, dtype="<f4").tobytes()
+
+  if url.path == "/batch":
+    pre_mat = predict(req_bod)
+    return 'application/json', json.dumps([float(f'{p:.3f}') for p in pre_mat]).encode()
+
+  pre_mat = predict([req_bod])
+  return 'text/html', f'{pre_mat[0]:.3f}'.encode()
+
+################################################################################
+
 def load_model(p):
   m = xgb.Booster()
 
@@ -77,12 +174,130 @@ def load_model(p):
 
 ################################################################################
 
-def normalize(l):
-  l = np.where(l > 0.85, 1, l)
-  l = np.where(((l >= 0.15) & (l <= 0.85)), 0.5, l)
-  l = np.where(l < 0.15, 0, l)
+def objective(mod):
+  return json.loads(mod.save_config())["learner"]["objective"]["name"]
+
+################################################################################
+
+{{ .Nor }}
+################################################################################
+
+def predict(input):
+  tes_mat = build_ensemble_matrix(fill_ens(context, input))
+  pre_mat = context["ens"].predict(tes_mat, iteration_range=(0, best_iteration(context["ens"]) + 1))
+
+  # Multiclass ensembles predict the most probable class of every input.
+  if context["obj"].startswith("multi:"):
+    return np.asarray([single(p) for p in np.asarray(pre_mat).reshape(len(input), -1)])
 
-  return l
+  return pre_mat
+
+################################################################################
+
+def predict_detail(input, out=False):
+  mod_out = {} if out else None
+  tes_mat = build_ensemble_matrix(fill_ens(context, input), mod_out)
+  ite_ran = (0, best_iteration(context["ens"]) + 1)
+
+  mar_mat = np.asarray(context["ens"].predict(tes_mat, iteration_range=ite_ran, output_margin=True), dtype=np.float32).reshape(len(input), -1)
+
+  if context["obj"].startswith("multi:"):
+    pro_mat = np.exp(mar_mat - mar_mat.max(axis=1, keepdims=True))
+    pro_mat = pro_mat / pro_mat.sum(axis=1, keepdims=True)
+    lab_vec = pro_mat.argmax(axis=1).astype(float)
+  else:
+    pro_mat = np.asarray(context["ens"].predict(tes_mat, iteration_range=ite_ran), dtype=np.float32).reshape(len(input), -1)
+    lab_vec = normalize(pro_mat[:, 0])
+
+  res = []
+
+  for i in range(len(input)):
+    res.append({
+      "lab": float(lab_vec[i]),
+      "mar": mar_mat[i].tolist(),
+      "pro": pro_mat[i].tolist(),
+    })
+
+    if out:
+      res[i]["mod"] = {buf: {buc: single(mod_out[buf][buc][i]) for buc in BUCKET} for buf in BUFFER}
+
+  return res
+
+################################################################################
+
+def predict_explain(input):
+  mod_out = {}
+  tes_mat = build_ensemble_matrix(fill_ens(context, input), mod_out)
+  mod_con = {}
+
+  # Multiclass bucket models are stacked as one ensemble feature per class.
+  num_col = {buf: {buc: len(columns(mod_out[buf][buc], context[buf]["obj"][buc])) for buc in BUCKET} for buf in BUFFER}
+
+  for buf in BUFFER:
+    m = xgb.DMatrix(context[buf]["ens"])
+    mod_con[buf] = {}
+
+    for buc in BUCKET:
+      mod = context[buf]["mod"][buc]
+      mod_con[buf][buc] = np.asarray(mod.predict(m, iteration_range=(0, best_iteration(mod) + 1), pred_contribs=True), dtype=np.float32).reshape(len(input), -1)
+
+  num_fea = sum(num_col[buf][buc] for buf in BUFFER for buc in BUCKET)
+  ens_con = np.asarray(context["ens"].predict(tes_mat, iteration_range=(0, best_iteration(context["ens"]) + 1), pred_contribs=True), dtype=np.float32).reshape(len(input), -1, num_fea + 1)
+
+  res = []
+
+  for i in range(len(input)):
+    ens = {}
+    col = 0
+    for buf in BUFFER:
+      ens[buf] = {}
+      for buc in BUCKET:
+        ens[buf][buc] = ens_con[i, :, col:col + num_col[buf][buc]].sum(axis=1).tolist()
+        col += num_col[buf][buc]
+
+    res.append({
+      "bia": ens_con[i, :, num_fea].tolist(),
+      "ens": ens,
+      "mod": {buf: {buc: mod_con[buf][buc][i].tolist() for buc in BUCKET} for buf in BUFFER},
+    })
+
+  return res
+
+################################################################################
+
+def predict_leaves(input):
+  tes_mat = build_ensemble_matrix(fill_ens(context, input))
+  mod_lea = {}
+
+  for buf in BUFFER:
+    m = xgb.DMatrix(context[buf]["ens"])
+    mod_lea[buf] = {}
+
+    for buc in BUCKET:
+      mod = context[buf]["mod"][buc]
+      mod_lea[buf][buc] = np.asarray(mod.predict(m, iteration_range=(0, best_iteration(mod) + 1), pred_leaf=True), dtype=np.int32).reshape(len(input), -1)
+
+  ens_lea = np.asarray(context["ens"].predict(tes_mat, iteration_range=(0, best_iteration(context["ens"]) + 1), pred_leaf=True), dtype=np.int32).reshape(len(input), -1)
+
+  res = []
+
+  for i in range(len(input)):
+    res.append({
+      "ens": ens_lea[i].tolist(),
+      "mod": {buf: {buc: mod_lea[buf][buc][i].tolist() for buc in BUCKET} for buf in BUFFER},
+    })
+
+  return res
+
+################################################################################
+
+def single(pre):
+  pre = np.asarray(pre).reshape(-1)
+
+  if len(pre) == 1:
+    return float(pre[0])
+
+  return float(pre.argmax())
 
 ################################################################################
 
@@ -91,42 +306,110 @@ context = fill_mod({})
 ################################################################################
 
 class S(BaseHTTPRequestHandler):
-    def _set_response(self):
-        self.send_response(200)
-        self.send_header('Content-type', 'text/html')
+    def _set_response(self, con_typ='text/html', sta=200):
+        self.send_response(sta)
+        self.send_header('Content-type', con_typ)
         self.end_headers()
 
     def do_GET(self):
         self._set_response()
-        self.wfile.write("OK\n".encode("utf-8"))
+        self.wfile.write(("OK " + NONCE + "\n").encode("utf-8"))
 
     def do_POST(self):
         con_len = int(self.headers.get('Content-Length'))
-        req_bod = json.loads(self.rfile.read(con_len).decode('utf-8'))
-        tes_mat = build_ensemble_matrix(fill_ens(context, req_bod))
-        pre_mat = context["ens"].predict(tes_mat, iteration_range=(0, context["ens"].best_iteration + 1))
 
-        self._set_response()
-        self.wfile.write(f'{pre_mat[0]:.3f}'.encode())
+        try:
+            con_typ, res_bod = handle(self.path, self.headers.get('Content-Type'), self.rfile.read(con_len))
+        except Exception:
+            self._set_response('text/plain', 500)
+            self.wfile.write(traceback.format_exc().encode())
+            return
+
+        self._set_response(con_typ)
+        self.wfile.write(res_bod)
 
     def log_message(self, format, *args):
         return
 
 ################################################################################
 
+class UnixHTTPServer(HTTPServer):
+    address_family = socket.AF_UNIX
+    # Connecting to a Unix domain socket fails right away once the listen
+    # backlog is full, instead of being retried like with TCP.
+    request_queue_size = 128
+
+    def server_bind(self):
+        socketserver.TCPServer.server_bind(self)
+        self.server_name = "localhost"
+        self.server_port = 0
+
+################################################################################
+
 def run(server_class=HTTPServer, handler_class=S, addr="{{ .Add }}", port={{ .Por }}):
+{{- if .Soc }}
+    if os.path.exists("{{ .Soc }}"):
+        os.remove("{{ .Soc }}")
+
+    umask = os.umask(0o177)
+    httpd = UnixHTTPServer("{{ .Soc }}", handler_class)
+    os.umask(umask)
+{{- else }}
     httpd = server_class((addr, port), handler_class)
+{{- end }}
     print('Starting http server')
 
+    # SIGTERM stops serving once the current request is answered. The server
+    # has to be shut down from another thread, since serve_forever is blocking
+    # the main thread, which is where signal handlers are executed.
+    signal.signal(signal.SIGTERM, lambda signum, frame: threading.Thread(target=httpd.shutdown).start())
+
     try:
         httpd.serve_forever()
     except KeyboardInterrupt:
         pass
 
     httpd.server_close()
+{{- if .Soc }}
+
+    # The socket of a reloaded child process got renamed over the socket of
+    # the child process it replaced, and is cleaned up by the loader instead.
+    if os.path.exists("{{ .Soc }}"):
+        os.remove("{{ .Soc }}")
+{{- end }}
     print('Stopping http server')
 
 ################################################################################
 
+def run_pipe():
+    PIPE.write(("READY " + NONCE + "\n").encode("utf-8"))
+    PIPE.flush()
+
+    while True:
+        hea = sys.stdin.buffer.read(4)
+        if len(hea) < 4:
+            break
+
+        fra = sys.stdin.buffer.read(struct.unpack(">I", hea)[0])
+        req_id, pat_len = struct.unpack_from(">QH", fra, 0)
+        pat = fra[10:10 + pat_len].decode("utf-8")
+        typ_len, = struct.unpack_from(">H", fra, 10 + pat_len)
+        typ = fra[12 + pat_len:12 + pat_len + typ_len].decode("utf-8")
+
+        try:
+            sta = 200
+            _, res_bod = handle(pat, typ, fra[12 + pat_len + typ_len:])
+        except Exception:
+            sta = 500
+            res_bod = traceback.format_exc().encode()
+
+        PIPE.write(struct.pack(">IQH", 10 + len(res_bod), req_id, sta) + res_bod)
+        PIPE.flush()
+
+################################################################################
+{{ if .Pip }}
+run_pipe()
+{{- else }}
 run()
+{{- end }}
 `
@@ -1,10 +1,116 @@
 package loader
 
 import (
+	"context"
 	"errors"
 	"os"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost/runner"
 )
 
+var canceledError = &tracer.Error{
+	Kind: "canceledError",
+}
+
+func IsCanceled(err error) bool {
+	return errors.Is(err, canceledError)
+}
+
+var childExitedError = &tracer.Error{
+	Kind: "childExitedError",
+}
+
+func IsChildExited(err error) bool {
+	return errors.Is(err, childExitedError)
+}
+
+var deadlineExceededError = &tracer.Error{
+	Kind: "deadlineExceededError",
+}
+
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, deadlineExceededError)
+}
+
+func IsInUse(err error) bool {
+	return runner.IsInUse(err)
+}
+
+var invalidConfigError = &tracer.Error{
+	Kind: "invalidConfigError",
+}
+
+func IsInvalidConfig(err error) bool {
+	return errors.Is(err, invalidConfigError)
+}
+
+var invalidInputError = &tracer.Error{
+	Kind: "invalidInputError",
+}
+
+func IsInvalidInput(err error) bool {
+	return errors.Is(err, invalidInputError)
+}
+
+var invalidResponseError = &tracer.Error{
+	Kind: "invalidResponseError",
+}
+
+func IsInvalidResponse(err error) bool {
+	return errors.Is(err, invalidResponseError)
+}
+
+var noWorkerError = &tracer.Error{
+	Kind: "noWorkerError",
+}
+
+func IsNoWorker(err error) bool {
+	return errors.Is(err, noWorkerError)
+}
+
+var notRestoredError = &tracer.Error{
+	Kind: "notRestoredError",
+}
+
+func IsNotRestored(err error) bool {
+	return errors.Is(err, notRestoredError)
+}
+
+var pidfileCorruptError = &tracer.Error{
+	Kind: "pidfileCorruptError",
+}
+
+func IsPidfileCorrupt(err error) bool {
+	return errors.Is(err, pidfileCorruptError)
+}
+
 func IsProcessAlreadyFinished(err error) bool {
 	return errors.Is(err, os.ErrProcessDone)
 }
+
+var reloadInProgressError = &tracer.Error{
+	Kind: "reloadInProgressError",
+}
+
+func IsReloadInProgress(err error) bool {
+	return errors.Is(err, reloadInProgressError)
+}
+
+var requestFailedError = &tracer.Error{
+	Kind: "requestFailedError",
+}
+
+func IsRequestFailed(err error) bool {
+	return errors.Is(err, requestFailedError)
+}
+
+// ctxerr translates the error of the given, already finished context into
+// one of the typed errors of this package.
+func ctxerr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return tracer.Maskf(deadlineExceededError, "%s", ctx.Err())
+	}
+
+	return tracer.Maskf(canceledError, "%s", ctx.Err())
+}
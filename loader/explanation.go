@@ -0,0 +1,27 @@
+package loader
+
+// Explanation describes how a single ensemble prediction came about, in terms
+// of SHAP values as computed by XGBoost using pred_contribs=True. All
+// contributions are in margin space, that is before applying the objective
+// transform. The contributions of a model plus its bias sum up to its margin.
+type Explanation struct {
+	// Bia is the bias of the ensemble, one value per output group.
+	Bia []float32 `json:"bia"`
+	// Ens is the contribution of every normalized bucket model output to the
+	// ensemble, per buffer hash and bucket, one value per output group. The
+	// contributions of the class probabilities of multiclass bucket models
+	// are summed up.
+	//
+	//     map[string]map[string][]float32{
+	//         "foo": { "a": []float32{ 0.41 }, "b": []float32{ -0.07 } },
+	//         "bar": { "a": []float32{ 0.19 }, "b": []float32{ 0.02 } },
+	//     }
+	//
+	Ens map[string]map[string][]float32 `json:"ens"`
+	// Mod is the contribution of every input feature to every bucket model,
+	// per buffer hash and bucket. The contributions are ordered like the
+	// feature vector without its leading label value. The last value is the
+	// bias of the bucket model. Multiclass bucket models list these values
+	// once per class.
+	Mod map[string]map[string][]float32 `json:"mod"`
+}
@@ -0,0 +1,40 @@
+package loader
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// freeport asks the kernel for a free port on the given address. The port is
+// released again before it is handed to the child process, so another process
+// may claim it in the meantime. The nonce based readiness check protects us
+// from talking to any such process.
+func freeport(add string) (int, error) {
+	lis, err := net.Listen("tcp", net.JoinHostPort(add, "0"))
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	por := lis.Addr().(*net.TCPAddr).Port
+
+	err = lis.Close()
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return por, nil
+}
+
+func nonce() (string, error) {
+	byt := make([]byte, 16)
+
+	_, err := rand.Read(byt)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	return hex.EncodeToString(byt), nil
+}
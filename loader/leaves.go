@@ -0,0 +1,19 @@
+package loader
+
+// Leaves contains the leaf indices a single input lands in, as computed by
+// XGBoost using pred_leaf=True. Every slice is indexed by tree, in the order
+// the trees got boosted. Only the trees up to and including the best iteration
+// of the respective model are considered.
+type Leaves struct {
+	// Ens is the leaf index per tree of the ensemble.
+	Ens []int32 `json:"ens"`
+	// Mod is the leaf index per tree of every bucket model, per buffer hash
+	// and bucket.
+	//
+	//     map[string]map[string][]int32{
+	//         "foo": { "a": []int32{ 7, 12, 3 }, "b": []int32{ 4, 9, 9 } },
+	//         "bar": { "a": []int32{ 8, 11, 5 }, "b": []int32{ 3, 3, 10 } },
+	//     }
+	//
+	Mod map[string]map[string][]int32 `json:"mod"`
+}
@@ -2,31 +2,62 @@ package loader
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"text/template"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost"
+	"github.com/xh3b4sd/xgboost/python"
+	"github.com/xh3b4sd/xgboost/runner"
 )
 
 type Loader struct {
 	Add string
+	// Bin enables the compact binary wire format for predictions instead of
+	// JSON. Inputs are sent as raw little endian float32 arrays in the sorted
+	// order of Buf, and predictions are returned at full precision instead of
+	// being rounded to 3 decimals.
+	Bin bool
 	// Buc is the required bucket list.
 	Buc []string
 	// Buf is the required list of buffer hashes for loading this ensemble.
 	Buf []string
+	// Cli is the optional HTTP client used for requests to the child process.
+	// Defaults to a client dialing Soc if Soc is set. A Cli given together with
+	// Soc is used as it is, and must therefore dial Soc on its own, e.g. in
+	// order to configure timeouts.
 	Cli *http.Client
 	Cmd *exec.Cmd
 	Deb bool
 	Fil *os.File
+	// Hoo contains optional hooks for capturing the output of the child
+	// process, classifying its exit and retaining its temp file.
+	Hoo runner.Hooks
+	// Nam is the optional name distinguishing multiple loaders sharing the
+	// same Pat, e.g. the workers of a Pool. Nam is part of the file names the
+	// loader keeps its bookkeeping in, e.g. "<Pat>/loader-<Nam>.pid".
+	Nam string
+	// Nor optionally configures how probabilities are normalized, which must
+	// be the same for training and serving. Defaults to
+	// xgboost.NormalizeThreshold. Restoring fails if Nor differs from the
+	// normalization recorded next to the saved models.
+	Nor xgboost.Normalize
+	// Out enables returning the raw prediction of every bucket model per
+	// buffer hash and bucket as part of detailed predictions.
+	Out bool
 	// Pat is the required data path containing all ensemble data.
 	//
 	//     $ tree -L 1 /Users/xh3b4sd/dat/
@@ -44,104 +75,253 @@ type Loader struct {
 	//     └── ensemble.ubj
 	//
 	Pat string
-	// Por is the required free port number used to run a simple HTTP server in
-	// Python for serving predictions between processes.
+	// Pde enables the parent death signal on Linux, which kills the child
+	// process once the current process exits, even if the current process
+	// got killed itself without any chance to clean up.
+	Pde bool
+	// Pip enables the stdin/stdout pipe protocol between processes instead of
+	// running an HTTP server in Python. Requests are written to stdin of the
+	// child process and responses are read from its stdout. Add, Cli, Por, Soc
+	// and Url are ignored if Pip is set.
+	Pip bool
+	// Por is the optional free port number used to run a simple HTTP server in
+	// Python for serving predictions between processes. A free port is picked
+	// automatically if Por is 0, in which case Por and Url are updated to
+	// reflect the chosen port.
 	Por int
-	// Tem is the required Python script template that is first being rendered
-	// and persisted, and then executed in a child process.
+	// Pyt optionally configures the Python interpreter, its environment and
+	// the resource limits of the child process.
+	Pyt python.Config
+	// Rep is optionally called with errors occurring in the background, that
+	// is the child process exiting unexpectedly and supervised restarts of the
+	// child process failing. Rep may be called concurrently.
+	Rep func(error)
+	// Soc is the optional path of a Unix domain socket used instead of TCP to
+	// serve predictions between processes, e.g. "<Pat>/loader.soc". The socket
+	// is only accessible to the owner of the current process. Add and Por are
+	// ignored if Soc is set.
+	Soc string
+	// Sup enables supervising the child process once Restore succeeded. A
+	// child process exiting unexpectedly is restarted with exponential backoff,
+	// and requests that failed because of it are retried once the restarted
+	// child process is ready. Cmd and Fil are replaced on every restart. See
+	// Status for the restart count and the last exit state, and Rep for being
+	// notified about failed restarts.
+	Sup bool
+	// Tem is the optional Python script template that is first being rendered
+	// and persisted, and then executed in a child process. Defaults to the
+	// default template. The child process must answer readiness checks, that
+	// is GET requests, with "OK {{ .Non }}", where {{ .Non }} renders the
+	// random nonce of the loader. With Pip, the child process must write
+	// "READY {{ .Non }}" to stdout instead. Restore fails with an invalid
+	// response error if a readiness check is answered without the nonce.
 	Tem string
 	Url string
+	// Wai enables waiting for another process to release its lock on Pat,
+	// instead of failing right away with an in use error.
+	Wai bool
+
+	// chi is the current child process.
+	chi *runner.Child
+	// end ensures that the child process is only released once per Restore.
+	end *sync.Once
+	// fli tracks the requests in flight to the current child process.
+	fli *sync.WaitGroup
+	// loc is the lock file held from Restore until the child process got
+	// released, so that no other process uses the same bookkeeping files.
+	loc *os.File
+	// mut guards the state of the current child process and its supervisor,
+	// which are replaced when restarting supervised child processes and on
+	// Reload.
+	mut sync.RWMutex
+	// pip is the pipe protocol to the child process, if Pip is set.
+	pip *pipe
+	// rel is held while reloading, since concurrent reloads would share the
+	// bookkeeping files and the endpoint of the new child process.
+	rel sync.Mutex
+	// non is the random nonce the child process of this loader responds with
+	// on readiness checks, so that we never mistake another process listening
+	// on the same port for our own child process.
+	non string
+	// sup is the supervisor of the child process, if Sup is set.
+	sup *supervisor
 }
 
 func (l *Loader) Execute() ([]byte, error) {
 	{
-		l.configs()
-	}
-
-	var buf bytes.Buffer
-	{
-		t, err := template.New("loader").Parse(l.Tem)
+		err := l.configs()
 		if err != nil {
 			return nil, tracer.Mask(err)
 		}
+	}
 
-		err = t.Execute(&buf, l.mapping())
-		if err != nil {
-			return nil, tracer.Mask(err)
-		}
+	byt, err := runner.Render("loader", l.Tem, l.mapping())
+	if err != nil {
+		return nil, tracer.Mask(err)
 	}
 
-	return buf.Bytes(), nil
+	return byt, nil
 }
 
 func (l *Loader) Restore() error {
+	return l.RestoreContext(context.Background())
+}
+
+func (l *Loader) RestoreContext(ctx context.Context) error {
 	var err error
 
 	{
-		l.configs()
-		l.cleanup()
+		l.unsupervise()
 	}
 
-	var byt []byte
 	{
-		byt, err = l.Execute()
+		err = l.configs()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
 	{
-		l.Fil, err = os.CreateTemp("", "xgboost-loader-template-*")
+		err = l.Pyt.Check(ctx, python.Serving)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	if l.loc == nil {
+		l.loc, err = runner.Lock(ctx, l.locfilp(), l.Wai)
+		if err != nil && ctx.Err() != nil {
+			return ctxerr(ctx)
+		} else if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	{
+		l.mut.Lock()
+		l.end = &sync.Once{}
+		l.mut.Unlock()
+	}
+
+	var chi *runner.Child
 	{
-		_, err := l.Fil.Write(byt)
+		chi, err = l.restore(ctx)
 		if err != nil {
+			_ = runner.Unlock(l.loc)
+			l.loc = nil
 			return tracer.Mask(err)
 		}
 	}
 
 	{
-		l.Cmd = exec.Command("python3", l.Fil.Name())
+		l.watch(chi)
+	}
+
+	if l.Sup {
+		sup := newsup()
+
+		l.mut.Lock()
+		l.sup = sup
+		l.mut.Unlock()
+
+		go l.supervise(sup, chi)
 	}
 
-	if l.Deb {
-		l.Cmd.Stdout = os.Stdout
-		l.Cmd.Stderr = os.Stderr
+	return nil
+}
+
+func (l *Loader) Reload() error {
+	return l.ReloadContext(context.Background())
+}
+
+// ReloadContext starts a new child process for the current content of Pat and
+// waits for it to be ready while the current child process keeps serving
+// predictions. Once ready, all new requests are routed to the new child
+// process, and the old child process is shut down after its requests in flight
+// finished, or the given context got canceled. If the new child process fails
+// to become ready, e.g. because the updated models cannot be loaded, the error
+// is returned and the current child process keeps serving predictions. Por and
+// Url are updated to reflect the new child process. A reload in progress error
+// is returned if another reload is still in progress.
+func (l *Loader) ReloadContext(ctx context.Context) error {
+	var err error
+
+	if !l.rel.TryLock() {
+		return tracer.Maskf(reloadInProgressError, "Loader.Reload must not be called concurrently")
 	}
+	defer l.rel.Unlock()
 
 	{
-		err := l.Cmd.Start()
+		err = l.configs()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
-	go func() {
-		err := l.Cmd.Wait()
+	var fli *sync.WaitGroup
+	{
+		l.mut.RLock()
+		fli = l.fli
+		l.mut.RUnlock()
+	}
+
+	if fli == nil {
+		return l.RestoreContext(ctx)
+	}
+
+	nxt := l.sibling()
+	{
+		err = nxt.configs()
 		if err != nil {
-			fmt.Printf("%s - %s\n", l.Fil.Name(), err.Error())
+			return tracer.Mask(err)
 		}
-	}()
+	}
 
+	var chi *runner.Child
 	{
-		err := l.Fil.Close()
+		chi, err = nxt.restore(ctx)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
-	for {
-		if l.checker() {
-			break
+	// Renaming the socket of the new child process over the socket of the old
+	// child process atomically routes new connections to the new child
+	// process, while established connections stay with the old one.
+	if l.Soc != "" {
+		err := os.Rename(nxt.Soc, l.Soc)
+		if err != nil {
+			_ = nxt.Sigkill()
+			return tracer.Mask(err)
 		}
+	}
 
-		{
-			time.After(3 * time.Second)
+	{
+		l.unsupervise()
+	}
+
+	var old *Loader
+	{
+		l.mut.Lock()
+
+		old = &Loader{Cmd: l.Cmd, Fil: l.Fil, chi: l.chi, fli: l.fli, pip: l.pip}
+
+		l.Cmd = nxt.Cmd
+		l.Fil = nxt.Fil
+		l.chi = nxt.chi
+		l.fli = nxt.fli
+		l.pip = nxt.pip
+
+		if l.Soc == "" {
+			l.Por = nxt.Por
+			l.Url = nxt.Url
 		}
+
+		l.mut.Unlock()
+	}
+
+	{
+		l.watch(chi)
 	}
 
 	{
@@ -153,46 +333,80 @@ func (l *Loader) Restore() error {
 		if err != nil {
 			return tracer.Mask(err)
 		}
+		err = os.Remove(nxt.pidfilp())
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		err = os.Remove(nxt.temfilp())
+		if err != nil {
+			return tracer.Mask(err)
+		}
 	}
 
-	return nil
-}
+	if l.Sup {
+		sup := newsup()
 
-func (l *Loader) Predict(inp map[string][]float32) (float32, error) {
-	var err error
+		l.mut.Lock()
+		l.sup = sup
+		l.mut.Unlock()
+
+		go l.supervise(sup, chi)
+	}
+
+	if l.Soc != "" {
+		l.Cli.CloseIdleConnections()
+	}
 
-	var byt []byte
 	{
-		byt, err = json.Marshal(inp)
-		if err != nil {
-			return 0, tracer.Mask(err)
+		don := make(chan struct{})
+		go func() {
+			old.fli.Wait()
+			close(don)
+		}()
+
+		select {
+		case <-don:
+		case <-ctx.Done():
 		}
 	}
 
-	var req *http.Request
+	if old.pip != nil {
+		_ = old.pip.close()
+	}
+
 	{
-		req, err = http.NewRequest("POST", l.Url, bytes.NewBuffer(byt))
+		err = old.chi.Kill()
 		if err != nil {
-			return 0, tracer.Mask(err)
+			return tracer.Mask(err)
+		}
+		err = old.chi.Remove()
+		if err != nil {
+			return tracer.Mask(err)
 		}
 	}
 
-	{
-		req.Header.Set("Content-Type", "application/json")
-	}
+	return nil
+}
 
-	var res *http.Response
-	{
-		res, err = l.Cli.Do(req)
+func (l *Loader) Predict(inp map[string][]float32) (float32, error) {
+	return l.PredictContext(context.Background(), inp)
+}
+
+func (l *Loader) PredictContext(ctx context.Context, inp map[string][]float32) (float32, error) {
+	var err error
+
+	if l.Bin {
+		pre, err := l.PredictBatchContext(ctx, []map[string][]float32{inp})
 		if err != nil {
 			return 0, tracer.Mask(err)
 		}
-		defer res.Body.Close()
+
+		return pre[0], nil
 	}
 
 	var bod []byte
 	{
-		bod, err = ioutil.ReadAll(res.Body)
+		bod, err = l.request(ctx, "/", inp)
 		if err != nil {
 			return 0, tracer.Mask(err)
 		}
@@ -200,7 +414,7 @@ func (l *Loader) Predict(inp map[string][]float32) (float32, error) {
 
 	var flo float64
 	{
-		flo, err = strconv.ParseFloat(string(bod), 32)
+		flo, err = strconv.ParseFloat(strings.TrimSpace(string(bod)), 32)
 		if err != nil {
 			return 0, tracer.Mask(err)
 		}
@@ -209,175 +423,1101 @@ func (l *Loader) Predict(inp map[string][]float32) (float32, error) {
 	return float32(flo), nil
 }
 
-func (l *Loader) Sigkill() error {
-	l.cleanup()
-	return nil
+func (l *Loader) PredictBatch(inp []map[string][]float32) ([]float32, error) {
+	return l.PredictBatchContext(context.Background(), inp)
 }
 
-func (l *Loader) checker() bool {
+func (l *Loader) PredictBatchContext(ctx context.Context, inp []map[string][]float32) ([]float32, error) {
 	var err error
 
-	var req *http.Request
+	if len(inp) == 0 {
+		return nil, nil
+	}
+
+	if l.Bin {
+		var byt []byte
+		{
+			byt, err = encbin(l.Buf, inp)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+
+		var bod []byte
+		{
+			bod, err = l.exchange(ctx, "/batch", conbin, byt)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+
+		var pre []float32
+		{
+			pre, err = decbin(bod, len(inp))
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+
+		return pre, nil
+	}
+
+	var bod []byte
 	{
-		req, err = http.NewRequest("GET", l.Url, nil)
+		bod, err = l.request(ctx, "/batch", inp)
 		if err != nil {
-			panic(err)
+			return nil, tracer.Mask(err)
 		}
 	}
 
-	var res *http.Response
+	var pre []float32
 	{
-		res, err = l.Cli.Do(req)
+		err = json.Unmarshal(bod, &pre)
 		if err != nil {
-			return false
+			return nil, tracer.Mask(err)
 		}
-		defer res.Body.Close()
+	}
+
+	return pre, nil
+}
+
+func (l *Loader) PredictDetail(inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	return l.PredictDetailContext(context.Background(), inp)
+}
+
+func (l *Loader) PredictDetailContext(ctx context.Context, inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	var err error
+
+	if len(inp) == 0 {
+		return nil, nil
+	}
+
+	pat := "/detail"
+	if l.Out {
+		pat += "?out=true"
 	}
 
 	var bod []byte
 	{
-		bod, err = ioutil.ReadAll(res.Body)
+		bod, err = l.rowwise(ctx, pat, inp)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var pre []xgboost.Prediction
+	{
+		err = json.Unmarshal(bod, &pre)
 		if err != nil {
-			panic(err)
+			return nil, tracer.Mask(err)
+		}
+
+		if len(pre) != len(inp) {
+			return nil, tracer.Maskf(invalidResponseError, "expected %d predictions, got %d", len(inp), len(pre))
 		}
 	}
 
-	return strings.TrimSpace(string(bod)) == "OK"
+	return pre, nil
+}
+
+func (l *Loader) Explain(inp []map[string][]float32) ([]Explanation, error) {
+	return l.ExplainContext(context.Background(), inp)
 }
 
-func (l *Loader) cleanup() {
-	if exists(l.pidfilp()) {
-		pro, err := os.FindProcess(l.pidfilc())
+// ExplainContext returns the feature contributions of every bucket model and
+// of the ensemble for every given input, as computed by XGBoost using
+// pred_contribs=True.
+func (l *Loader) ExplainContext(ctx context.Context, inp []map[string][]float32) ([]Explanation, error) {
+	var err error
+
+	if len(inp) == 0 {
+		return nil, nil
+	}
+
+	var bod []byte
+	{
+		bod, err = l.rowwise(ctx, "/explain", inp)
 		if err != nil {
-			panic(err)
+			return nil, tracer.Mask(err)
 		}
+	}
 
-		err = pro.Kill()
-		if IsProcessAlreadyFinished(err) {
-			// fall through
-		} else if err != nil {
-			panic(err)
-		}
-
-		for {
-			// In case a pidfile was found we deal with an orphaned process that
-			// will never be a child process of the current process inspecting the
-			// orphan. Process.Wait will therefore always return an error, which we
-			// simply ignore. All we care about is the exit information of the
-			// orphan.
-			//
-			//     wait: no child processes
-			//
-			sta, _ := pro.Wait()
-			if sta == nil || sta.Exited() {
-				break
-			}
+	var exp []Explanation
+	{
+		err = json.Unmarshal(bod, &exp)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
 
-			{
-				time.After(3 * time.Second)
-			}
+		if len(exp) != len(inp) {
+			return nil, tracer.Maskf(invalidResponseError, "expected %d explanations, got %d", len(inp), len(exp))
 		}
+	}
+
+	return exp, nil
+}
+
+func (l *Loader) Leaves(inp []map[string][]float32) ([]Leaves, error) {
+	return l.LeavesContext(context.Background(), inp)
+}
+
+// LeavesContext returns the leaf indices of every bucket model and of the
+// ensemble for every given input, as computed by XGBoost using pred_leaf=True.
+func (l *Loader) LeavesContext(ctx context.Context, inp []map[string][]float32) ([]Leaves, error) {
+	var err error
+
+	if len(inp) == 0 {
+		return nil, nil
+	}
 
-		err = os.Remove(l.pidfilp())
+	var bod []byte
+	{
+		bod, err = l.rowwise(ctx, "/leaves", inp)
 		if err != nil {
-			panic(err)
+			return nil, tracer.Mask(err)
 		}
 	}
 
-	if exists(l.temfilp()) {
-		if exists(l.temfilc()) {
-			err := os.Remove(l.temfilc())
-			if err != nil {
-				panic(err)
-			}
+	var lea []Leaves
+	{
+		err = json.Unmarshal(bod, &lea)
+		if err != nil {
+			return nil, tracer.Mask(err)
 		}
 
-		err := os.Remove(l.temfilp())
-		if err != nil {
-			panic(err)
+		if len(lea) != len(inp) {
+			return nil, tracer.Maskf(invalidResponseError, "expected %d leaves, got %d", len(inp), len(lea))
 		}
 	}
+
+	return lea, nil
 }
 
-func (l *Loader) configs() {
-	if l.Add == "" {
-		l.Add = "localhost"
+// Shutdown gracefully shuts down the child process. Requests in flight are
+// allowed to finish, before the child process is asked to terminate and given
+// time to stop serving. Once the given context gets canceled, the child
+// process group is killed like with Sigkill. The bookkeeping files of the
+// loader are cleaned up in any case, and only once per Restore, no matter how
+// often Shutdown or Sigkill are called.
+func (l *Loader) Shutdown(ctx context.Context) error {
+	err := l.release(func() error {
+		return l.shutdown(ctx)
+	})
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+func (l *Loader) Sigkill() error {
+	err := l.release(func() error {
+		return nil
+	})
+	if err != nil {
+		return tracer.Mask(err)
 	}
 
+	return nil
+}
+
+// Validate returns an invalid config error if any of the required fields is
+// not set, or if any of the nested configurations is invalid.
+func (l *Loader) Validate() error {
 	if len(l.Buc) == 0 {
-		panic("Loader.Buc must not be empty")
+		return tracer.Maskf(invalidConfigError, "Loader.Buc must not be empty")
 	}
 
 	if len(l.Buf) == 0 {
-		panic("Ensemble.Buf must not be empty")
+		return tracer.Maskf(invalidConfigError, "Loader.Buf must not be empty")
 	}
 
-	if l.Cli == nil {
-		l.Cli = &http.Client{}
+	if strings.ContainsRune(l.Nam, filepath.Separator) {
+		return tracer.Maskf(invalidConfigError, "Loader.Nam must not contain path separators")
 	}
 
 	if l.Pat == "" {
-		panic("Loader.Pat must not be empty")
+		return tracer.Maskf(invalidConfigError, "Loader.Pat must not be empty")
 	}
 
-	if l.Por == 0 {
-		panic("Loader.Por must not be empty")
+	// The maximum length of Unix domain socket paths differs between
+	// platforms. 104 bytes is the lowest common denominator of Linux and
+	// Darwin, including the terminating null byte.
+	if len(l.Soc) >= 104 {
+		return tracer.Maskf(invalidConfigError, "Loader.Soc must not be longer than 103 bytes")
 	}
 
-	if l.Tem == "" {
-		l.Tem = deftem
+	if l.Pip && l.Soc != "" {
+		return tracer.Maskf(invalidConfigError, "Loader.Pip and Loader.Soc must not be used together")
 	}
 
-	if l.Url == "" {
-		l.Url = fmt.Sprintf("http://%s:%d", l.Add, l.Por)
+	{
+		err := l.Nor.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Loader.Nor is invalid, %s", err)
+		}
 	}
-}
 
-func (l *Loader) mapping() map[string]interface{} {
-	return map[string]interface{}{
-		"Add": l.Add,
-		"Buc": l.Buc,
-		"Buf": l.Buf,
-		"Pat": strings.TrimSuffix(l.Pat, "/"),
-		"Por": l.Por,
+	{
+		err := l.Pyt.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Loader.Pyt is invalid, %s", err)
+		}
 	}
-}
 
-func (l *Loader) pidfilb() []byte {
-	return []byte(fmt.Sprintf("%d\n", l.Cmd.Process.Pid))
+	return nil
 }
 
-func (l *Loader) pidfilc() int {
-	byt, err := ioutil.ReadFile(l.pidfilp())
-	if err != nil {
-		panic(err)
+func (l *Loader) checker(ctx context.Context) (bool, error) {
+	var err error
+
+	if l.Pip {
+		return l.pip.ready(), nil
 	}
 
-	pid, err := strconv.Atoi(strings.TrimSpace(string(byt)))
-	if err != nil {
-		panic(err)
+	var req *http.Request
+	{
+		req, err = http.NewRequestWithContext(ctx, "GET", l.Url, nil)
+		if err != nil {
+			return false, tracer.Mask(err)
+		}
 	}
 
-	return pid
-}
+	var res *http.Response
+	{
+		res, err = l.Cli.Do(req)
+		if err != nil {
+			return false, nil
+		}
+		defer res.Body.Close()
+	}
 
-func (l *Loader) pidfilp() string {
-	return filepath.Join(l.Pat, "loader.pid")
-}
+	var bod []byte
+	{
+		bod, err = ioutil.ReadAll(res.Body)
+		if err != nil {
+			return false, nil
+		}
+	}
 
-func (l *Loader) temfilb() []byte {
-	return []byte(l.Fil.Name())
-}
+	if strings.TrimSpace(string(bod)) == "OK "+l.non {
+		return true, nil
+	}
 
-func (l *Loader) temfilc() string {
-	byt, err := ioutil.ReadFile(l.temfilp())
-	if err != nil {
-		panic(err)
+	// Anything else answering readiness checks successfully is either not our
+	// child process, or a template not rendering the nonce, both of which
+	// never become ready.
+	if res.StatusCode == http.StatusOK {
+		return false, tracer.Maskf(invalidResponseError, "readiness check must be answered with the nonce of Loader.Tem, got %q", bytes.TrimSpace(bod))
 	}
 
-	return strings.TrimSpace(string(byt))
+	return false, nil
 }
 
-func (l *Loader) temfilp() string {
-	return filepath.Join(l.Pat, "loader.pat")
+func (l *Loader) cleanup() error {
+	var err error
+
+	if l.pip != nil {
+		// Closing stdin of a child process that already went away fails
+		// because the pipe is broken, which is fine.
+		_ = l.pip.close()
+	}
+
+	var exi bool
+	{
+		exi, err = runner.Exists(l.pidfilp())
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if exi {
+		var pid int
+		{
+			pid, err = l.pidfilc()
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		var pro *os.Process
+		{
+			pro, err = os.FindProcess(pid)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		// The orphan is expected to lead its own process group, which is killed
+		// as a whole. Pidfiles written before process groups got introduced
+		// refer to processes that do not lead any group, which are still killed
+		// on their own.
+		{
+			err = runner.Kill(pid)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		{
+			err = pro.Kill()
+			if IsProcessAlreadyFinished(err) {
+				// fall through
+			} else if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		// In case a pidfile was found we deal with an orphaned process that will
+		// never be a child process of the current process inspecting the orphan.
+		// Process.Wait would therefore always return an error right away.
+		//
+		//     wait: no child processes
+		//
+		// Instead we probe the orphan with signal 0 until it is gone, for at most
+		// 3 seconds, since an orphan not reaped by its parent lingers as zombie.
+		for i := 0; i < 30; i++ {
+			err = pro.Signal(syscall.Signal(0))
+			if err != nil {
+				break
+			}
+
+			{
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+
+		{
+			err = os.Remove(l.pidfilp())
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	}
+
+	{
+		err = l.runner().Cleanup()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if l.Soc != "" {
+		{
+			exi, err = runner.Exists(l.Soc)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		if exi {
+			err := os.Remove(l.Soc)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *Loader) configs() error {
+	{
+		err := l.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if l.Add == "" {
+		l.Add = "localhost"
+	}
+
+	if l.Cli == nil && l.Soc != "" {
+		l.Cli = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+					var dia net.Dialer
+					return dia.DialContext(ctx, "unix", l.Soc)
+				},
+			},
+		}
+	}
+
+	if l.Cli == nil {
+		l.Cli = &http.Client{}
+	}
+
+	if l.Tem == "" {
+		l.Tem = deftem
+	}
+
+	// Por and Url are guarded, since Reload moves them over to the new child
+	// process.
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.Por == 0 && l.Soc == "" && !l.Pip {
+		por, err := freeport(l.Add)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		l.Por = por
+	}
+
+	if l.Url == "" && l.Soc != "" {
+		l.Url = "http://unix"
+	}
+
+	if l.Url == "" {
+		l.Url = fmt.Sprintf("http://%s:%d", l.Add, l.Por)
+	}
+
+	if l.non == "" {
+		non, err := nonce()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		l.non = non
+	}
+
+	return nil
+}
+
+// exchange sends the given request body of the given content type to the
+// child process and returns the response body. If the child process is
+// supervised and the request failed because the child process went away, the
+// request is retried once the restarted child process is ready.
+func (l *Loader) exchange(ctx context.Context, pat string, typ string, byt []byte) ([]byte, error) {
+	var err error
+
+	var sup *supervisor
+	{
+		l.mut.RLock()
+		sup = l.sup
+		l.mut.RUnlock()
+	}
+
+	if sup == nil {
+		return l.transfer(ctx, pat, typ, byt)
+	}
+
+	gen := sup.generation()
+
+	bod, fai := l.transfer(ctx, pat, typ, byt)
+	if fai == nil || !retryable(fai) {
+		return bod, fai
+	}
+
+	var ok bool
+	{
+		ok, err = sup.await(ctx, gen)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	if !ok {
+		return nil, fai
+	}
+
+	return l.transfer(ctx, pat, typ, byt)
+}
+
+// child returns the current child process, if any.
+func (l *Loader) child() *runner.Child {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	return l.chi
+}
+
+// exited returns whether the current child process exited.
+func (l *Loader) exited() bool {
+	chi := l.child()
+	if chi == nil {
+		return false
+	}
+
+	select {
+	case <-chi.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Loader) locfilp() string {
+	return filepath.Join(l.Pat, l.prefix()+".lock")
+}
+
+func (l *Loader) mapping() map[string]interface{} {
+	return map[string]interface{}{
+		"Add": l.Add,
+		"Buc": l.Buc,
+		"Buf": l.Buf,
+		"Non": l.non,
+		"Nor": l.Nor.Script(),
+		"Pat": strings.TrimSuffix(l.Pat, "/"),
+		"Pip": l.Pip,
+		"Por": l.Por,
+		"Soc": l.Soc,
+	}
+}
+
+// norfilp returns the files recording the normalization of the saved models.
+func (l *Loader) norfilp() []string {
+	fil := []string{filepath.Join(l.Pat, "ensemble.nor.json")}
+	for _, buf := range l.Buf {
+		fil = append(fil, filepath.Join(l.Pat, buf, "mod", "nor.json"))
+	}
+
+	return fil
+}
+
+func (l *Loader) pidfilb() []byte {
+	return []byte(fmt.Sprintf("%d\n", l.Cmd.Process.Pid))
+}
+
+func (l *Loader) pidfilc() (int, error) {
+	byt, err := ioutil.ReadFile(l.pidfilp())
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(byt)))
+	if err != nil {
+		return 0, tracer.Maskf(pidfileCorruptError, "%s", err)
+	}
+
+	return pid, nil
+}
+
+func (l *Loader) pidfilp() string {
+	return filepath.Join(l.Pat, l.prefix()+".pid")
+}
+
+// prefix returns the file name prefix of the bookkeeping files of this
+// loader.
+func (l *Loader) prefix() string {
+	if l.Nam == "" {
+		return "loader"
+	}
+
+	return "loader-" + l.Nam
+}
+
+// release stops supervising the child process, shuts it down using the given
+// function, cleans up and releases the lock on Pat, only once per Restore. The
+// state of the released child process is cleared, so that requests fail with
+// a not restored error and Reload falls back to Restore.
+func (l *Loader) release(fun func() error) error {
+	var err error
+
+	run := func() {
+		l.unsupervise()
+
+		{
+			l.mut.RLock()
+			chi := l.chi
+			l.mut.RUnlock()
+
+			if chi != nil {
+				chi.Expect()
+			}
+		}
+
+		err = fun()
+
+		cle := l.cleanup()
+		if err == nil {
+			err = cle
+		}
+
+		{
+			l.mut.Lock()
+			l.chi = nil
+			l.fli = nil
+			l.pip = nil
+			l.mut.Unlock()
+		}
+
+		unl := runner.Unlock(l.loc)
+		if err == nil {
+			err = unl
+		}
+
+		l.loc = nil
+	}
+
+	var end *sync.Once
+	{
+		l.mut.RLock()
+		end = l.end
+		l.mut.RUnlock()
+	}
+
+	if end == nil {
+		run()
+	} else {
+		end.Do(run)
+	}
+
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// request sends the given input encoded as JSON to the child process and
+// returns the response body.
+func (l *Loader) request(ctx context.Context, pat string, inp interface{}) ([]byte, error) {
+	var err error
+
+	var byt []byte
+	{
+		byt, err = json.Marshal(inp)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var bod []byte
+	{
+		bod, err = l.exchange(ctx, pat, conjsn, byt)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	return bod, nil
+}
+
+// restore starts a new child process and waits for it to be ready.
+func (l *Loader) restore(ctx context.Context) (*runner.Child, error) {
+	var err error
+
+	{
+		err = l.cleanup()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		err = l.Nor.Check(l.norfilp()...)
+		if err != nil {
+			return nil, tracer.Maskf(invalidConfigError, "Loader.Nor is invalid, %s", err)
+		}
+	}
+
+	var byt []byte
+	{
+		byt, err = l.Execute()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	// Lines the child process writes to stdout before the handshake of the
+	// pipe protocol are forwarded to wherever stdout would have been wired
+	// to otherwise.
+	var out io.Writer
+	var pip *pipe
+
+	run := l.runner()
+	if l.Pip {
+		run.Set = func(cmd *exec.Cmd) error {
+			out = cmd.Stdout
+
+			p, err := newpipe(cmd)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			pip = p
+
+			return nil
+		}
+	}
+
+	var chi *runner.Child
+	{
+		chi, err = run.Start(byt)
+		if err != nil {
+			if pip != nil {
+				pip.discard()
+			}
+
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		l.mut.Lock()
+		l.Cmd = chi.Cmd
+		l.Fil = chi.Fil
+		l.chi = chi
+		l.fli = &sync.WaitGroup{}
+		l.pip = pip
+		l.mut.Unlock()
+	}
+
+	if pip != nil {
+		err := pip.start(l.non, out)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	for {
+		var ok bool
+		{
+			ok, err = l.checker(ctx)
+			if err != nil {
+				{
+					err := chi.Kill()
+					if err != nil {
+						return nil, tracer.Mask(err)
+					}
+				}
+
+				{
+					<-chi.Done()
+				}
+
+				{
+					err := run.Cleanup()
+					if err != nil {
+						return nil, tracer.Mask(err)
+					}
+				}
+
+				return nil, tracer.Mask(err)
+			}
+		}
+
+		if ok {
+			break
+		}
+
+		select {
+		case <-chi.Done():
+			{
+				err := run.Cleanup()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+			}
+
+			err := chi.Err()
+			if err == nil {
+				return nil, tracer.Maskf(childExitedError, "exit status 0")
+			}
+
+			return nil, tracer.Maskf(childExitedError, "%s", err)
+		case <-ctx.Done():
+			{
+				err := chi.Kill()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+			}
+
+			{
+				<-chi.Done()
+			}
+
+			{
+				err := run.Cleanup()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+			}
+
+			return nil, ctxerr(ctx)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	{
+		err = ioutil.WriteFile(l.pidfilp(), l.pidfilb(), 0664)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	return chi, nil
+}
+
+// rowwise sends the given batch of inputs to the given path, using the binary
+// wire format if configured and JSON otherwise.
+func (l *Loader) rowwise(ctx context.Context, pat string, inp []map[string][]float32) ([]byte, error) {
+	if !l.Bin {
+		return l.request(ctx, pat, inp)
+	}
+
+	byt, err := encbin(l.Buf, inp)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	bod, err := l.exchange(ctx, pat, conbin, byt)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return bod, nil
+}
+
+// shutdown waits for the requests in flight to finish and asks the child
+// process to terminate, either by closing its stdin in case of the pipe
+// protocol, or by sending SIGTERM. The child process group is killed once the
+// given context gets canceled.
+func (l *Loader) shutdown(ctx context.Context) error {
+	var chi *runner.Child
+	var fli *sync.WaitGroup
+	var pip *pipe
+	{
+		// Requests issued from now on are not waited for, since adding to the
+		// wait group while waiting for it is not allowed.
+		l.mut.Lock()
+		chi = l.chi
+		fli = l.fli
+		pip = l.pip
+		l.fli = nil
+		l.mut.Unlock()
+	}
+
+	if chi == nil || fli == nil {
+		return nil
+	}
+
+	{
+		chi.Expect()
+	}
+
+	{
+		don := make(chan struct{})
+		go func() {
+			fli.Wait()
+			close(don)
+		}()
+
+		select {
+		case <-don:
+		case <-ctx.Done():
+		}
+	}
+
+	if ctx.Err() == nil {
+		if pip != nil {
+			_ = pip.close()
+		} else {
+			err := chi.Term()
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		select {
+		case <-chi.Done():
+			return nil
+		case <-ctx.Done():
+		}
+	}
+
+	{
+		err := chi.Kill()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	{
+		<-chi.Done()
+	}
+
+	return ctxerr(ctx)
+}
+
+// report calls Rep with the given error, if Rep is set.
+func (l *Loader) report(err error) {
+	if l.Rep != nil {
+		l.Rep(err)
+	}
+}
+
+func (l *Loader) runner() runner.Runner {
+	return runner.Runner{
+		Deb: l.Deb,
+		Hoo: l.Hoo,
+		Mar: l.temfilp(),
+		Nam: "loader",
+		Pde: l.Pde,
+		Pyt: l.Pyt,
+	}
+}
+
+// sibling returns an unrestored loader with the same configuration, but with
+// its own bookkeeping files and endpoint, so that its child process can run
+// next to the current one.
+func (l *Loader) sibling() *Loader {
+	nam := "reload"
+	if l.Nam != "" {
+		nam = l.Nam + "-reload"
+	}
+
+	s := &Loader{
+		Add: l.Add,
+		Bin: l.Bin,
+		Buc: l.Buc,
+		Buf: l.Buf,
+		Deb: l.Deb,
+		Hoo: l.Hoo,
+		Nam: nam,
+		Nor: l.Nor,
+		Out: l.Out,
+		Pat: l.Pat,
+		Pde: l.Pde,
+		Pip: l.Pip,
+		Pyt: l.Pyt,
+		Tem: l.Tem,
+		non: l.non,
+	}
+
+	if l.Soc != "" {
+		s.Soc = l.Soc + ".reload"
+	} else {
+		s.Cli = l.Cli
+	}
+
+	return s
+}
+
+func (l *Loader) temfilb() []byte {
+	return []byte(l.Fil.Name())
+}
+
+func (l *Loader) temfilp() string {
+	return filepath.Join(l.Pat, l.prefix()+".pat")
+}
+
+// transfer sends the given request body of the given content type to the
+// current child process and returns the response body.
+func (l *Loader) transfer(ctx context.Context, pat string, typ string, byt []byte) ([]byte, error) {
+	var err error
+
+	var fli *sync.WaitGroup
+	var pip *pipe
+	var url string
+	{
+		l.mut.RLock()
+		fli = l.fli
+		pip = l.pip
+		url = l.Url
+		if fli != nil {
+			fli.Add(1)
+		}
+		l.mut.RUnlock()
+	}
+
+	// The configuration got validated and completed by Restore, which is why
+	// there is nothing to do for requests without a running child process.
+	if fli == nil {
+		return nil, tracer.Maskf(notRestoredError, "loader child process is not running")
+	}
+
+	defer fli.Done()
+
+	if l.Pip {
+		sta, bod, err := pip.request(ctx, pat, typ, byt)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		if sta != http.StatusOK {
+			return nil, tracer.Maskf(requestFailedError, "%d %s", sta, bod)
+		}
+
+		return bod, nil
+	}
+
+	var req *http.Request
+	{
+		req, err = http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(url, "/")+pat, bytes.NewBuffer(byt))
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		req.Header.Set("Content-Type", typ)
+	}
+
+	var res *http.Response
+	{
+		res, err = l.Cli.Do(req)
+		if ctx.Err() != nil {
+			return nil, ctxerr(ctx)
+		} else if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		defer res.Body.Close()
+	}
+
+	var bod []byte
+	{
+		bod, err = ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, tracer.Maskf(requestFailedError, "%d %s", res.StatusCode, bod)
+	}
+
+	return bod, nil
+}
+
+// unsupervise stops supervising the child process, if it is supervised.
+func (l *Loader) unsupervise() {
+	var sup *supervisor
+	{
+		l.mut.Lock()
+		sup = l.sup
+		l.sup = nil
+		l.mut.Unlock()
+	}
+
+	if sup != nil {
+		sup.stop()
+	}
+}
+
+// watch reports the exit of the given child process via Rep, unless the exit
+// is expected, e.g. because the child process got shut down or replaced.
+func (l *Loader) watch(chi *runner.Child) {
+	if l.Rep == nil {
+		return
+	}
+
+	go func() {
+		<-chi.Done()
+
+		if chi.Expected() {
+			return
+		}
+
+		err := chi.Err()
+		if err == nil {
+			l.report(tracer.Maskf(childExitedError, "%s - exit status 0", chi.Fil.Name()))
+		} else {
+			l.report(tracer.Maskf(childExitedError, "%s - %s", chi.Fil.Name(), err))
+		}
+	}()
 }
@@ -0,0 +1,256 @@
+//go:build !windows
+
+package loader
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xh3b4sd/xgboost/python"
+)
+
+// testem is a template standing in for the default template, which answers
+// readiness checks and predictions without requiring any models or Python
+// packages. It takes a moment to become ready, the way loading models does.
+const testem = `
+import time
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+class S(BaseHTTPRequestHandler):
+    def do_GET(self):
+        self.send_response(200)
+        self.end_headers()
+        self.wfile.write(("OK {{ .Non }}\n").encode())
+
+    def do_POST(self):
+        self.rfile.read(int(self.headers.get('Content-Length')))
+        self.send_response(200)
+        self.end_headers()
+        self.wfile.write(b"0.123")
+
+    def log_message(self, *args):
+        return
+
+time.sleep(0.3)
+HTTPServer(("{{ .Add }}", {{ .Por }}), S).serve_forever()
+`
+
+func Test_Loader_Reload_Concurrent(t *testing.T) {
+	l := testldr(t)
+
+	{
+		err := l.Restore()
+		if err != nil {
+			t.Fatalf("expected restore to succeed, got %#v", err)
+		}
+	}
+
+	fir := make(chan error, 1)
+	go func() {
+		fir <- l.Reload()
+	}()
+
+	// The new child process of the first reload takes a moment to become
+	// ready, during which the second reload must not interfere.
+	{
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	{
+		err := l.Reload()
+		if !IsReloadInProgress(err) {
+			t.Fatalf("expected reload in progress error, got %#v", err)
+		}
+	}
+
+	{
+		err := <-fir
+		if err != nil {
+			t.Fatalf("expected first reload to succeed, got %#v", err)
+		}
+	}
+
+	{
+		pre, err := l.Predict(testinp())
+		if err != nil {
+			t.Fatalf("expected predict to succeed, got %#v", err)
+		}
+
+		if pre != 0.123 {
+			t.Fatalf("expected 0.123, got %f", pre)
+		}
+	}
+
+	for _, f := range []string{"loader-reload.pid", "loader-reload.pat"} {
+		_, err := os.Stat(filepath.Join(l.Pat, f))
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %#v", f, err)
+		}
+	}
+
+	// Reloading works again once the first reload finished.
+	{
+		err := l.Reload()
+		if err != nil {
+			t.Fatalf("expected reload to succeed, got %#v", err)
+		}
+	}
+}
+
+// Test_Loader_Restore_Nonce ensures that a template answering readiness checks
+// without the nonce, the way templates written before the nonce did, fails
+// Restore instead of polling forever.
+func Test_Loader_Restore_Nonce(t *testing.T) {
+	l := testldr(t)
+	l.Tem = strings.Replace(testem, "OK {{ .Non }}", "OK", 1)
+
+	err := l.RestoreContext(testctx(t))
+	if !IsInvalidResponse(err) {
+		t.Fatalf("expected invalid response error, got %#v", err)
+	}
+
+	if !testgon(l.Cmd.Process) {
+		t.Fatalf("expected child process to be killed")
+	}
+}
+
+func Test_Loader_Sigkill_Release(t *testing.T) {
+	l := testldr(t)
+
+	{
+		err := l.Restore()
+		if err != nil {
+			t.Fatalf("expected restore to succeed, got %#v", err)
+		}
+	}
+
+	pro := l.Cmd.Process
+
+	{
+		err := l.Sigkill()
+		if err != nil {
+			t.Fatalf("expected sigkill to succeed, got %#v", err)
+		}
+	}
+
+	{
+		_, err := l.Predict(testinp())
+		if !IsNotRestored(err) {
+			t.Fatalf("expected not restored error, got %#v", err)
+		}
+	}
+
+	{
+		_, err := os.Stat(l.pidfilp())
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected pidfile to be removed, got %#v", err)
+		}
+	}
+
+	if !testgon(pro) {
+		t.Fatalf("expected child process to be killed")
+	}
+
+	// Reloading a released loader restores it, including the lock on Pat and
+	// the bookkeeping files, which a later Sigkill cleans up again.
+	{
+		err := l.Reload()
+		if err != nil {
+			t.Fatalf("expected reload to succeed, got %#v", err)
+		}
+	}
+
+	{
+		pre, err := l.Predict(testinp())
+		if err != nil {
+			t.Fatalf("expected predict to succeed, got %#v", err)
+		}
+
+		if pre != 0.123 {
+			t.Fatalf("expected 0.123, got %f", pre)
+		}
+	}
+
+	{
+		_, err := os.Stat(l.pidfilp())
+		if err != nil {
+			t.Fatalf("expected pidfile to exist, got %#v", err)
+		}
+	}
+
+	{
+		err := l.Sigkill()
+		if err != nil {
+			t.Fatalf("expected sigkill to succeed, got %#v", err)
+		}
+	}
+
+	{
+		_, err := os.Stat(l.pidfilp())
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected pidfile to be removed, got %#v", err)
+		}
+	}
+}
+
+// testctx returns a context bounding a test which would otherwise hang.
+func testctx(t *testing.T) context.Context {
+	ctx, can := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(can)
+	return ctx
+}
+
+// testgon returns whether the given child process went away within 3 seconds.
+func testgon(pro *os.Process) bool {
+	for i := 0; i < 30; i++ {
+		err := pro.Signal(syscall.Signal(0))
+		if err != nil {
+			return true
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return false
+}
+
+func testinp() map[string][]float32 {
+	return map[string][]float32{"f": {0, 1}}
+}
+
+func testldr(t *testing.T) *Loader {
+	t.Helper()
+
+	{
+		_, err := exec.LookPath("python3")
+		if err != nil {
+			t.Skip("python3 is not available")
+		}
+	}
+
+	dir := t.TempDir()
+
+	l := &Loader{
+		Buc: []string{"a"},
+		Buf: []string{"f"},
+		Pat: dir,
+		Pyt: python.Config{Ski: true},
+		Tem: testem,
+	}
+
+	t.Cleanup(func() {
+		_ = l.Sigkill()
+		_, err := os.Stat(filepath.Join(dir, "loader.pid"))
+		if !os.IsNotExist(err) {
+			t.Errorf("expected pidfile to be removed, got %#v", err)
+		}
+	})
+
+	return l
+}
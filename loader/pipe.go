@@ -0,0 +1,304 @@
+package loader
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// pipe implements the stdin/stdout protocol between the Go process and the
+// Python child process. After starting, the child writes a single handshake
+// line containing the nonce of the loader.
+//
+//     READY <nonce>\n
+//
+// Afterwards every request written to stdin is a length prefixed frame, with
+// all integers encoded in big endian byte order.
+//
+//     uint32 length of the rest of the frame
+//     uint64 request ID
+//     uint16 length of the request path
+//     []byte request path, e.g. /batch
+//     uint16 length of the content type
+//     []byte content type, e.g. application/json
+//     []byte request body
+//
+// Every response read from stdout is a length prefixed frame carrying the ID
+// of the request it answers. Responses may be written in any order.
+//
+//     uint32 length of the rest of the frame
+//     uint64 request ID
+//     uint16 status code, 200 on success
+//     []byte response body, or the error message on failure
+//
+type pipe struct {
+	// cmd are the pipe ends handed to the child process, which are closed in
+	// the parent process once the child process got started.
+	cmd []*os.File
+	err error
+	mut sync.Mutex
+	nxt uint64
+	// own are the pipe ends kept by the parent process.
+	own []*os.File
+	pen map[uint64]chan response
+	rdy chan struct{}
+	rea *bufio.Reader
+	wri io.WriteCloser
+	wmu sync.Mutex
+}
+
+type response struct {
+	sta uint16
+	bod []byte
+}
+
+// newpipe wires stdin and stdout of the given command, which must not be
+// started yet.
+func newpipe(cmd *exec.Cmd) (*pipe, error) {
+	inr, inw, err := os.Pipe()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	our, ouw, err := os.Pipe()
+	if err != nil {
+		_ = inr.Close()
+		_ = inw.Close()
+		return nil, tracer.Mask(err)
+	}
+
+	{
+		cmd.Stdin = inr
+		cmd.Stdout = ouw
+	}
+
+	p := &pipe{
+		cmd: []*os.File{inr, ouw},
+		own: []*os.File{inw, our},
+		pen: map[uint64]chan response{},
+		rdy: make(chan struct{}),
+		rea: bufio.NewReader(our),
+		wri: inw,
+	}
+
+	return p, nil
+}
+
+// start closes the pipe ends of the child process within the parent process
+// and starts reading from the child process in the background. Lines the
+// child process writes before the handshake are forwarded to the given
+// writer, if any.
+func (p *pipe) start(non string, deb io.Writer) error {
+	for _, f := range p.cmd {
+		err := f.Close()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	go func() {
+		for {
+			lin, err := p.rea.ReadString('\n')
+			if err != nil {
+				p.failure(err)
+				return
+			}
+
+			if strings.TrimSpace(lin) == "READY "+non {
+				break
+			}
+
+			if deb != nil {
+				_, _ = io.WriteString(deb, lin)
+			}
+		}
+
+		close(p.rdy)
+
+		for {
+			err := p.reading()
+			if err != nil {
+				p.failure(err)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// discard closes all pipe ends, in case the child process could not be
+// started.
+func (p *pipe) discard() {
+	for _, f := range append(p.cmd, p.own...) {
+		_ = f.Close()
+	}
+}
+
+// ready returns whether the handshake of the child process got received.
+func (p *pipe) ready() bool {
+	select {
+	case <-p.rdy:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *pipe) request(ctx context.Context, pat string, typ string, byt []byte) (uint16, []byte, error) {
+	var res chan response
+	var rid uint64
+	{
+		p.mut.Lock()
+
+		if p.err != nil {
+			err := p.err
+			p.mut.Unlock()
+			return 0, nil, tracer.Maskf(childExitedError, "%s", err)
+		}
+
+		p.nxt++
+		rid = p.nxt
+		res = make(chan response, 1)
+		p.pen[rid] = res
+
+		p.mut.Unlock()
+	}
+
+	var fra []byte
+	{
+		fra = make([]byte, 4+8+2+len(pat)+2+len(typ)+len(byt))
+		off := 0
+
+		binary.BigEndian.PutUint32(fra[off:], uint32(len(fra)-4))
+		off += 4
+		binary.BigEndian.PutUint64(fra[off:], rid)
+		off += 8
+		binary.BigEndian.PutUint16(fra[off:], uint16(len(pat)))
+		off += 2
+		off += copy(fra[off:], pat)
+		binary.BigEndian.PutUint16(fra[off:], uint16(len(typ)))
+		off += 2
+		off += copy(fra[off:], typ)
+		copy(fra[off:], byt)
+	}
+
+	{
+		p.wmu.Lock()
+		_, err := p.wri.Write(fra)
+		p.wmu.Unlock()
+
+		if err != nil {
+			p.forget(rid)
+			return 0, nil, tracer.Mask(err)
+		}
+	}
+
+	select {
+	case r, ok := <-res:
+		if !ok {
+			p.mut.Lock()
+			err := p.err
+			p.mut.Unlock()
+			return 0, nil, tracer.Maskf(childExitedError, "%s", err)
+		}
+
+		return r.sta, r.bod, nil
+	case <-ctx.Done():
+		p.forget(rid)
+		return 0, nil, ctxerr(ctx)
+	}
+}
+
+// close closes the stdin of the child process, which causes the child process
+// to stop reading requests. Any pending or future request fails.
+func (p *pipe) close() error {
+	{
+		p.failure(io.ErrClosedPipe)
+	}
+
+	{
+		p.wmu.Lock()
+		err := p.wri.Close()
+		p.wmu.Unlock()
+
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// failure marks the pipe as broken and releases all pending requests.
+func (p *pipe) failure(err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+
+	for k, v := range p.pen {
+		close(v)
+		delete(p.pen, k)
+	}
+}
+
+func (p *pipe) forget(rid uint64) {
+	p.mut.Lock()
+	delete(p.pen, rid)
+	p.mut.Unlock()
+}
+
+// reading reads a single response frame and dispatches it to the pending
+// request it answers.
+func (p *pipe) reading() error {
+	var hea [4]byte
+	{
+		_, err := io.ReadFull(p.rea, hea[:])
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var fra []byte
+	{
+		fra = make([]byte, binary.BigEndian.Uint32(hea[:]))
+
+		_, err := io.ReadFull(p.rea, fra)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if len(fra) < 10 {
+			return tracer.Maskf(invalidResponseError, "pipe frame must be at least 10 bytes")
+		}
+	}
+
+	rid := binary.BigEndian.Uint64(fra[0:8])
+	res := response{
+		sta: binary.BigEndian.Uint16(fra[8:10]),
+		bod: fra[10:],
+	}
+
+	{
+		p.mut.Lock()
+		c, ok := p.pen[rid]
+		delete(p.pen, rid)
+		p.mut.Unlock()
+
+		if ok {
+			c <- res
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,380 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Loader_Pipe_Cancel(t *testing.T) {
+	p, c := testpip(t, nil)
+
+	ctx, can := context.WithCancel(context.Background())
+
+	don := make(chan error, 1)
+	go func() {
+		_, _, err := p.request(ctx, "/predict", conjsn, []byte("a"))
+		don <- err
+	}()
+
+	rid, _, _, _ := c.frame(t)
+
+	{
+		can()
+	}
+
+	{
+		err := <-don
+		if !IsCanceled(err) {
+			t.Fatalf("expected canceled error, got %#v", err)
+		}
+	}
+
+	{
+		p.mut.Lock()
+		pen := len(p.pen)
+		p.mut.Unlock()
+
+		if pen != 0 {
+			t.Fatalf("expected no pending requests, got %d", pen)
+		}
+	}
+
+	// The late response of the canceled request must neither block the pipe
+	// nor be delivered to the next request.
+	{
+		c.answer(t, rid, 200, []byte("late"))
+	}
+
+	go func() {
+		rid, _, _, bod := c.frame(t)
+		c.answer(t, rid, 200, bod)
+	}()
+
+	{
+		sta, bod, err := p.request(context.Background(), "/predict", conjsn, []byte("b"))
+		if err != nil {
+			t.Fatalf("expected request to succeed, got %#v", err)
+		}
+
+		if sta != 200 || string(bod) != "b" {
+			t.Fatalf("expected 200 b, got %d %s", sta, bod)
+		}
+	}
+}
+
+func Test_Loader_Pipe_Concurrent(t *testing.T) {
+	p, c := testpip(t, nil)
+
+	num := 64
+
+	// The child process answers every batch of 8 requests in reverse order,
+	// so that responses interleave across callers.
+	go func() {
+		for i := 0; i < num/8; i++ {
+			var rid []uint64
+			var bod [][]byte
+			for j := 0; j < 8; j++ {
+				r, _, _, b := c.frame(t)
+				rid = append(rid, r)
+				bod = append(bod, b)
+			}
+
+			for j := len(rid) - 1; j >= 0; j-- {
+				c.answer(t, rid[j], 200, bod[j])
+			}
+		}
+	}()
+
+	var wai sync.WaitGroup
+	err := make(chan error, num)
+
+	for i := 0; i < num; i++ {
+		wai.Add(1)
+		go func(i int) {
+			defer wai.Done()
+
+			exp := fmt.Sprintf("body %d", i)
+
+			_, bod, e := p.request(context.Background(), "/predict", conjsn, []byte(exp))
+			if e != nil {
+				err <- e
+			} else if string(bod) != exp {
+				err <- fmt.Errorf("expected %s, got %s", exp, bod)
+			}
+		}(i)
+	}
+
+	{
+		wai.Wait()
+		close(err)
+	}
+
+	for e := range err {
+		t.Fatalf("expected requests to succeed, got %#v", e)
+	}
+}
+
+func Test_Loader_Pipe_Failure(t *testing.T) {
+	p, c := testpip(t, nil)
+
+	don := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _, err := p.request(context.Background(), "/predict", conjsn, nil)
+			don <- err
+		}()
+	}
+
+	{
+		c.frame(t)
+		c.frame(t)
+	}
+
+	// The child process going away closes its stdout, which must release all
+	// pending requests.
+	{
+		err := c.out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-don:
+			if !IsChildExited(err) {
+				t.Fatalf("expected child exited error, got %#v", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expected pending requests to be released")
+		}
+	}
+
+	{
+		_, _, err := p.request(context.Background(), "/predict", conjsn, nil)
+		if !IsChildExited(err) {
+			t.Fatalf("expected child exited error, got %#v", err)
+		}
+	}
+}
+
+func Test_Loader_Pipe_Framing(t *testing.T) {
+	var deb bytes.Buffer
+	p, c := testpip(t, &deb)
+
+	if deb.String() != "starting\nREADY other\n" {
+		t.Fatalf("expected lines before the handshake to be forwarded, got %q", deb.String())
+	}
+
+	don := make(chan error, 1)
+	go func() {
+		sta, bod, err := p.request(context.Background(), "/batch", conbin, []byte{1, 2, 3})
+		if err == nil && (sta != 500 || string(bod) != "boom") {
+			err = fmt.Errorf("expected 500 boom, got %d %s", sta, bod)
+		}
+		don <- err
+	}()
+
+	rid, pat, typ, bod := c.frame(t)
+
+	if rid != 1 {
+		t.Fatalf("expected request ID 1, got %d", rid)
+	}
+	if pat != "/batch" {
+		t.Fatalf("expected /batch, got %s", pat)
+	}
+	if typ != conbin {
+		t.Fatalf("expected %s, got %s", conbin, typ)
+	}
+	if !bytes.Equal(bod, []byte{1, 2, 3}) {
+		t.Fatalf("expected [1 2 3], got %v", bod)
+	}
+
+	{
+		c.answer(t, rid, 500, []byte("boom"))
+	}
+
+	{
+		err := <-don
+		if err != nil {
+			t.Fatalf("expected request to succeed, got %#v", err)
+		}
+	}
+
+	{
+		err := p.close()
+		if err != nil {
+			t.Fatalf("expected close to succeed, got %#v", err)
+		}
+	}
+
+	{
+		_, err := c.inp.ReadByte()
+		if err != io.EOF {
+			t.Fatalf("expected stdin of the child process to be closed, got %#v", err)
+		}
+	}
+
+	{
+		_, _, err := p.request(context.Background(), "/batch", conbin, nil)
+		if !IsChildExited(err) {
+			t.Fatalf("expected child exited error, got %#v", err)
+		}
+	}
+}
+
+func Test_Loader_Pipe_Order(t *testing.T) {
+	p, c := testpip(t, nil)
+
+	res := make([]chan string, 2)
+	for i := range res {
+		res[i] = make(chan string, 1)
+	}
+
+	for i := range res {
+		go func(i int) {
+			_, bod, err := p.request(context.Background(), "/predict", conjsn, []byte(fmt.Sprint(i)))
+			if err != nil {
+				res[i] <- err.Error()
+			} else {
+				res[i] <- string(bod)
+			}
+		}(i)
+
+		// Ensure the requests are written in order.
+		{
+			c.frame(t)
+		}
+	}
+
+	// The second request is answered first, and no matter the order in which
+	// responses arrive, each reaches the request it answers.
+	{
+		c.answer(t, 2, 200, []byte("second"))
+	}
+
+	if bod := <-res[1]; bod != "second" {
+		t.Fatalf("expected second, got %s", bod)
+	}
+
+	{
+		c.answer(t, 1, 200, []byte("first"))
+	}
+
+	if bod := <-res[0]; bod != "first" {
+		t.Fatalf("expected first, got %s", bod)
+	}
+}
+
+// testchi is the child process end of a pipe, standing in for the Python
+// child process.
+type testchi struct {
+	inp *bufio.Reader
+	out *os.File
+}
+
+// answer writes a response frame for the given request ID.
+func (c *testchi) answer(t *testing.T, rid uint64, sta uint16, bod []byte) {
+	fra := make([]byte, 4+8+2+len(bod))
+	binary.BigEndian.PutUint32(fra[0:], uint32(len(fra)-4))
+	binary.BigEndian.PutUint64(fra[4:], rid)
+	binary.BigEndian.PutUint16(fra[12:], sta)
+	copy(fra[14:], bod)
+
+	_, err := c.out.Write(fra)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// frame reads the next request frame.
+func (c *testchi) frame(t *testing.T) (uint64, string, string, []byte) {
+	var hea [4]byte
+	{
+		_, err := io.ReadFull(c.inp, hea[:])
+		if err != nil {
+			t.Error(err)
+			return 0, "", "", nil
+		}
+	}
+
+	fra := make([]byte, binary.BigEndian.Uint32(hea[:]))
+	{
+		_, err := io.ReadFull(c.inp, fra)
+		if err != nil {
+			t.Error(err)
+			return 0, "", "", nil
+		}
+	}
+
+	rid := binary.BigEndian.Uint64(fra[0:])
+	off := 8
+	pat := string(fra[off+2 : off+2+int(binary.BigEndian.Uint16(fra[off:]))])
+	off += 2 + len(pat)
+	typ := string(fra[off+2 : off+2+int(binary.BigEndian.Uint16(fra[off:]))])
+	off += 2 + len(typ)
+
+	return rid, pat, typ, fra[off:]
+}
+
+// testpip returns a started pipe, the child process end of which is kept by
+// the test instead of being handed to a child process. The child process end
+// writes a line and a handshake with a different nonce before the actual
+// handshake.
+func testpip(t *testing.T, deb io.Writer) (*pipe, *testchi) {
+	t.Helper()
+
+	p, err := newpipe(&exec.Cmd{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testchi{
+		inp: bufio.NewReader(p.cmd[0]),
+		out: p.cmd[1],
+	}
+
+	t.Cleanup(func() {
+		for _, f := range append(p.cmd, p.own...) {
+			_ = f.Close()
+		}
+	})
+
+	{
+		cmd := p.cmd
+		p.cmd = nil
+
+		err := p.start("nonce", deb)
+		p.cmd = cmd
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		_, err := io.WriteString(c.out, strings.Join([]string{"starting", "READY other", "READY nonce", ""}, "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; !p.ready(); i++ {
+		if i == 300 {
+			t.Fatalf("expected handshake to be received")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return p, c
+}
@@ -0,0 +1,580 @@
+package loader
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost"
+)
+
+const (
+	// BalanceLeastOutstanding routes every request to the worker with the
+	// fewest requests in flight.
+	BalanceLeastOutstanding = "least-outstanding"
+	// BalanceRoundRobin routes requests to all workers in turn.
+	BalanceRoundRobin = "round-robin"
+)
+
+// Pool implements the xgboost.Loader interface by spreading predictions
+// across multiple loaders, each running its own Python child process for the
+// same ensemble. Requests failing because of a worker are retried once on
+// another worker. A worker whose child process exited is taken out of
+// rotation, drained and replaced in the background, no matter whether the
+// exit was noticed by a failing request or while the worker was idle.
+//
+//     poo := &loader.Pool{
+//         Buc: []string{ ... },
+//         Buf: []string{ ... },
+//         Num: 4,
+//         Pat: "/Users/xh3b4sd/dat/",
+//     }
+//
+type Pool struct {
+	// Bal is the optional balancing strategy, either BalanceRoundRobin or
+	// BalanceLeastOutstanding. Defaults to BalanceRoundRobin.
+	Bal string
+	// Buc is the required bucket list.
+	Buc []string
+	// Buf is the required list of buffer hashes for loading this ensemble.
+	Buf []string
+	// Con is optionally called with every worker before it gets restored, in
+	// order to apply further loader configuration, e.g. Bin or Pip.
+	Con func(*Loader)
+	Deb bool
+	// Nor optionally configures how probabilities are normalized by all
+	// workers, see Loader.Nor.
+	Nor xgboost.Normalize
+	// Num is the required number of workers.
+	Num int
+	// Pat is the required data path containing all ensemble data. Every worker
+	// keeps its own bookkeeping files in Pat, e.g. "<Pat>/loader-<i>.pid".
+	Pat string
+	// Rep is optionally called with errors occurring in the background, that
+	// is child processes of workers exiting unexpectedly and replacing workers
+	// failing. Rep may be called concurrently.
+	Rep func(error)
+	// Soc enables Unix domain sockets instead of TCP for all workers, using the
+	// socket path "<Pat>/loader-<i>.soc".
+	Soc bool
+	// Tem is the optional Python script template of all workers, see
+	// Loader.Tem.
+	Tem string
+
+	can context.CancelFunc
+	ctx context.Context
+	grp sync.WaitGroup
+	mut sync.RWMutex
+	nxt uint64
+	wor []*worker
+}
+
+type worker struct {
+	// hea is whether the worker is in rotation, guarded by the mutex of the
+	// pool.
+	hea bool
+	idx int
+	ldr *Loader
+	// out is the number of requests in flight, accessed atomically.
+	out int64
+}
+
+// Execute returns the rendered template of the first worker.
+func (p *Pool) Execute() ([]byte, error) {
+	{
+		err := p.configs()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	byt, err := p.loader(0).Execute()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return byt, nil
+}
+
+func (p *Pool) Restore() error {
+	return p.RestoreContext(context.Background())
+}
+
+// RestoreContext restores all workers one after another. If any worker fails
+// to restore, all workers restored so far are shut down again.
+func (p *Pool) RestoreContext(ctx context.Context) error {
+	var err error
+
+	{
+		err = p.configs()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		err = p.Sigkill()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var wor []*worker
+	for i := 0; i < p.Num; i++ {
+		l := p.loader(i)
+
+		err = l.RestoreContext(ctx)
+		if err != nil {
+			for _, w := range wor {
+				_ = w.ldr.Sigkill()
+			}
+
+			return tracer.Mask(err)
+		}
+
+		wor = append(wor, &worker{hea: true, idx: i, ldr: l})
+	}
+
+	{
+		p.mut.Lock()
+		p.ctx, p.can = context.WithCancel(context.Background())
+		p.wor = wor
+		for _, w := range wor {
+			p.watch(p.ctx, w)
+		}
+		p.mut.Unlock()
+	}
+
+	return nil
+}
+
+func (p *Pool) Explain(inp []map[string][]float32) ([]Explanation, error) {
+	return p.ExplainContext(context.Background(), inp)
+}
+
+// ExplainContext works like Loader.ExplainContext, using a worker picked
+// according to the balancing strategy.
+func (p *Pool) ExplainContext(ctx context.Context, inp []map[string][]float32) ([]Explanation, error) {
+	var exp []Explanation
+
+	err := p.call(ctx, func(l *Loader) error {
+		var err error
+		exp, err = l.ExplainContext(ctx, inp)
+		return err
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return exp, nil
+}
+
+func (p *Pool) Leaves(inp []map[string][]float32) ([]Leaves, error) {
+	return p.LeavesContext(context.Background(), inp)
+}
+
+// LeavesContext works like Loader.LeavesContext, using a worker picked
+// according to the balancing strategy.
+func (p *Pool) LeavesContext(ctx context.Context, inp []map[string][]float32) ([]Leaves, error) {
+	var lea []Leaves
+
+	err := p.call(ctx, func(l *Loader) error {
+		var err error
+		lea, err = l.LeavesContext(ctx, inp)
+		return err
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return lea, nil
+}
+
+func (p *Pool) Predict(inp map[string][]float32) (float32, error) {
+	return p.PredictContext(context.Background(), inp)
+}
+
+func (p *Pool) PredictContext(ctx context.Context, inp map[string][]float32) (float32, error) {
+	var pre float32
+
+	err := p.call(ctx, func(l *Loader) error {
+		var err error
+		pre, err = l.PredictContext(ctx, inp)
+		return err
+	})
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return pre, nil
+}
+
+func (p *Pool) PredictBatch(inp []map[string][]float32) ([]float32, error) {
+	return p.PredictBatchContext(context.Background(), inp)
+}
+
+func (p *Pool) PredictBatchContext(ctx context.Context, inp []map[string][]float32) ([]float32, error) {
+	var pre []float32
+
+	err := p.call(ctx, func(l *Loader) error {
+		var err error
+		pre, err = l.PredictBatchContext(ctx, inp)
+		return err
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return pre, nil
+}
+
+func (p *Pool) PredictDetail(inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	return p.PredictDetailContext(context.Background(), inp)
+}
+
+func (p *Pool) PredictDetailContext(ctx context.Context, inp []map[string][]float32) ([]xgboost.Prediction, error) {
+	var pre []xgboost.Prediction
+
+	err := p.call(ctx, func(l *Loader) error {
+		var err error
+		pre, err = l.PredictDetailContext(ctx, inp)
+		return err
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return pre, nil
+}
+
+// Shutdown stops replacing workers and gracefully shuts down all of them
+// concurrently, as described for Loader.Shutdown. The first error encountered
+// is returned after all workers got shut down.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	wor := p.finish()
+
+	var mut sync.Mutex
+	var err error
+	var grp sync.WaitGroup
+	for _, w := range wor {
+		grp.Add(1)
+		go func(w *worker) {
+			defer grp.Done()
+
+			e := w.ldr.Shutdown(ctx)
+			if e != nil {
+				mut.Lock()
+				if err == nil {
+					err = e
+				}
+				mut.Unlock()
+			}
+		}(w)
+	}
+
+	{
+		grp.Wait()
+	}
+
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// Sigkill stops replacing workers and shuts down all of them. The first error
+// encountered is returned after all workers got shut down.
+func (p *Pool) Sigkill() error {
+	wor := p.finish()
+
+	var err error
+	for _, w := range wor {
+		e := w.ldr.Sigkill()
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// Validate returns an invalid config error if any of the required fields is
+// not set, or if any of the nested configurations is invalid.
+func (p *Pool) Validate() error {
+	if p.Bal != "" && p.Bal != BalanceLeastOutstanding && p.Bal != BalanceRoundRobin {
+		return tracer.Maskf(invalidConfigError, "Pool.Bal must be %s or %s", BalanceLeastOutstanding, BalanceRoundRobin)
+	}
+
+	if len(p.Buc) == 0 {
+		return tracer.Maskf(invalidConfigError, "Pool.Buc must not be empty")
+	}
+
+	if len(p.Buf) == 0 {
+		return tracer.Maskf(invalidConfigError, "Pool.Buf must not be empty")
+	}
+
+	if p.Num < 1 {
+		return tracer.Maskf(invalidConfigError, "Pool.Num must be at least 1")
+	}
+
+	if p.Pat == "" {
+		return tracer.Maskf(invalidConfigError, "Pool.Pat must not be empty")
+	}
+
+	{
+		err := p.Nor.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Pool.Nor is invalid, %s", err)
+		}
+	}
+
+	return nil
+}
+
+// call executes the given request against a worker picked according to the
+// balancing strategy. If the request failed because of the worker, the request
+// is retried once on another worker, and the worker gets replaced if its child
+// process exited.
+func (p *Pool) call(ctx context.Context, fun func(*Loader) error) error {
+	var err error
+
+	var w *worker
+	for i := 0; i < 2; i++ {
+		{
+			w, err = p.pick(w)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		{
+			atomic.AddInt64(&w.out, 1)
+			err = fun(w.ldr)
+			atomic.AddInt64(&w.out, -1)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if !retryable(err) {
+			return tracer.Mask(err)
+		}
+
+		if w.ldr.exited() {
+			p.replace(w)
+		}
+	}
+
+	return tracer.Mask(err)
+}
+
+func (p *Pool) configs() error {
+	{
+		err := p.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.Bal == "" {
+		p.Bal = BalanceRoundRobin
+	}
+
+	return nil
+}
+
+// finish stops replacing workers and takes all workers out of the pool.
+func (p *Pool) finish() []*worker {
+	{
+		p.mut.Lock()
+
+		if p.can != nil {
+			p.can()
+		}
+
+		p.mut.Unlock()
+	}
+
+	{
+		p.grp.Wait()
+	}
+
+	var wor []*worker
+	{
+		p.mut.Lock()
+		wor = p.wor
+		p.wor = nil
+		p.mut.Unlock()
+	}
+
+	return wor
+}
+
+// loader returns the unrestored loader of the worker with the given index.
+func (p *Pool) loader(idx int) *Loader {
+	l := &Loader{
+		Buc: p.Buc,
+		Buf: p.Buf,
+		Deb: p.Deb,
+		Nam: strconv.Itoa(idx),
+		Nor: p.Nor,
+		Pat: p.Pat,
+		Rep: p.Rep,
+		Tem: p.Tem,
+	}
+
+	if p.Soc {
+		l.Soc = filepath.Join(p.Pat, l.prefix()+".soc")
+	}
+
+	if p.Con != nil {
+		p.Con(l)
+	}
+
+	return l
+}
+
+// pick returns the worker the next request should be routed to. The given
+// worker, if any, is only picked if there is no other worker in rotation.
+func (p *Pool) pick(skp *worker) (*worker, error) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+
+	var hea []*worker
+	for _, w := range p.wor {
+		if w.hea && w != skp {
+			hea = append(hea, w)
+		}
+	}
+
+	if len(hea) == 0 && skp != nil && skp.hea {
+		hea = append(hea, skp)
+	}
+
+	if len(p.wor) == 0 {
+		return nil, tracer.Maskf(noWorkerError, "Pool.Restore must be called first")
+	}
+
+	if len(hea) == 0 {
+		return nil, tracer.Maskf(noWorkerError, "all workers are being replaced")
+	}
+
+	if p.Bal == BalanceLeastOutstanding {
+		min := hea[0]
+		for _, w := range hea[1:] {
+			if atomic.LoadInt64(&w.out) < atomic.LoadInt64(&min.out) {
+				min = w
+			}
+		}
+
+		return min, nil
+	}
+
+	return hea[atomic.AddUint64(&p.nxt, 1)%uint64(len(hea))], nil
+}
+
+// report calls Rep with the given error, if Rep is set.
+func (p *Pool) report(err error) {
+	if p.Rep != nil {
+		p.Rep(err)
+	}
+}
+
+// replace takes the given worker out of rotation and replaces it in the
+// background, unless this already happens.
+func (p *Pool) replace(w *worker) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if !w.hea || p.ctx == nil || p.ctx.Err() != nil {
+		return
+	}
+
+	w.hea = false
+	p.grp.Add(1)
+
+	go p.respawn(p.ctx, w)
+}
+
+// respawn drains the given worker, shuts it down and restores a new worker in
+// its place, retrying with exponential backoff until the pool gets shut down.
+func (p *Pool) respawn(ctx context.Context, w *worker) {
+	defer p.grp.Done()
+
+	for atomic.LoadInt64(&w.out) > 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	{
+		err := w.ldr.Sigkill()
+		if err != nil {
+			p.report(err)
+		}
+	}
+
+	bac := supmin
+	for {
+		l := p.loader(w.idx)
+
+		err := l.RestoreContext(ctx)
+		if err == nil {
+			p.mut.Lock()
+			p.wor[w.idx] = &worker{hea: true, idx: w.idx, ldr: l}
+			p.watch(ctx, p.wor[w.idx])
+			p.mut.Unlock()
+
+			return
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.report(err)
+
+		select {
+		case <-time.After(bac):
+		case <-ctx.Done():
+			return
+		}
+
+		bac *= 2
+		if bac > supmax {
+			bac = supmax
+		}
+	}
+}
+
+// watch replaces the given worker in the background once its child process
+// exited, even if the worker is idle. Supervised workers restart their child
+// processes on their own and are not watched. watch must be called with the
+// mutex of the pool being locked.
+func (p *Pool) watch(ctx context.Context, w *worker) {
+	chi := w.ldr.child()
+	if chi == nil || w.ldr.Sup {
+		return
+	}
+
+	p.grp.Add(1)
+
+	go func() {
+		defer p.grp.Done()
+
+		select {
+		case <-chi.Done():
+			p.replace(w)
+		case <-ctx.Done():
+		}
+	}()
+}
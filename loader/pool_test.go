@@ -0,0 +1,471 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost/python"
+	"github.com/xh3b4sd/xgboost/runner"
+)
+
+func Test_Loader_Pool_Call(t *testing.T) {
+	testCases := []struct {
+		err []error
+		cal int
+		mat func(error) bool
+	}{
+		// Case 0, where the request is retried on another worker.
+		{
+			err: []error{childExitedError, nil},
+			cal: 2,
+			mat: func(err error) bool { return err == nil },
+		},
+		// Case 1, where the request is retried only once.
+		{
+			err: []error{childExitedError, childExitedError},
+			cal: 2,
+			mat: IsChildExited,
+		},
+		// Case 2
+		{
+			err: []error{invalidInputError},
+			cal: 1,
+			mat: IsInvalidInput,
+		},
+		// Case 3
+		{
+			err: []error{requestFailedError},
+			cal: 1,
+			mat: IsRequestFailed,
+		},
+		// Case 4
+		{
+			err: []error{canceledError},
+			cal: 1,
+			mat: IsCanceled,
+		},
+		// Case 5
+		{
+			err: []error{nil},
+			cal: 1,
+			mat: func(err error) bool { return err == nil },
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			p := testpoo(t, BalanceRoundRobin, 3)
+
+			var ldr []*Loader
+			err := p.call(context.Background(), func(l *Loader) error {
+				for _, w := range p.wor {
+					if w.ldr == l && atomic.LoadInt64(&w.out) != 1 {
+						t.Errorf("expected 1 request in flight, got %d", atomic.LoadInt64(&w.out))
+					}
+				}
+
+				ldr = append(ldr, l)
+				if tc.err[len(ldr)-1] == nil {
+					return nil
+				}
+
+				return tracer.Mask(tc.err[len(ldr)-1])
+			})
+			if !tc.mat(err) {
+				t.Fatalf("expected matching error, got %#v", err)
+			}
+
+			if len(ldr) != tc.cal {
+				t.Fatalf("expected %d calls, got %d", tc.cal, len(ldr))
+			}
+
+			if len(ldr) == 2 && ldr[0] == ldr[1] {
+				t.Fatalf("expected retry on another worker")
+			}
+
+			for _, w := range p.wor {
+				if atomic.LoadInt64(&w.out) != 0 {
+					t.Fatalf("expected no requests in flight, got %d", atomic.LoadInt64(&w.out))
+				}
+			}
+		})
+	}
+}
+
+func Test_Loader_Pool_Pick_LeastOutstanding(t *testing.T) {
+	testCases := []struct {
+		out []int64
+		hea []bool
+		skp int
+		idx int
+	}{
+		// Case 0
+		{
+			out: []int64{3, 1, 2},
+			hea: []bool{true, true, true},
+			skp: -1,
+			idx: 1,
+		},
+		// Case 1, where the skipped worker is not picked although it has the
+		// fewest requests in flight.
+		{
+			out: []int64{3, 1, 2},
+			hea: []bool{true, true, true},
+			skp: 1,
+			idx: 2,
+		},
+		// Case 2, where workers out of rotation are not picked.
+		{
+			out: []int64{3, 1, 2},
+			hea: []bool{true, false, true},
+			skp: -1,
+			idx: 2,
+		},
+		// Case 3, where the skipped worker is picked if it is the only one in
+		// rotation.
+		{
+			out: []int64{3, 1, 2},
+			hea: []bool{false, true, false},
+			skp: 1,
+			idx: 1,
+		},
+		// Case 4, where ties go to the first worker.
+		{
+			out: []int64{2, 0, 0},
+			hea: []bool{true, true, true},
+			skp: -1,
+			idx: 1,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			p := testpoo(t, BalanceLeastOutstanding, len(tc.out))
+
+			for j, w := range p.wor {
+				w.out = tc.out[j]
+				w.hea = tc.hea[j]
+			}
+
+			var skp *worker
+			if tc.skp != -1 {
+				skp = p.wor[tc.skp]
+			}
+
+			w, err := p.pick(skp)
+			if err != nil {
+				t.Fatalf("expected pick to succeed, got %#v", err)
+			}
+
+			if w.idx != tc.idx {
+				t.Fatalf("expected worker %d, got %d", tc.idx, w.idx)
+			}
+		})
+	}
+}
+
+func Test_Loader_Pool_Pick_NoWorker(t *testing.T) {
+	testCases := []struct {
+		hea []bool
+		skp int
+	}{
+		// Case 0, where the pool is not restored.
+		{
+			hea: nil,
+			skp: -1,
+		},
+		// Case 1, where all workers are being replaced.
+		{
+			hea: []bool{false, false},
+			skp: -1,
+		},
+		// Case 2, where the skipped worker is being replaced as well.
+		{
+			hea: []bool{false, false},
+			skp: 0,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			p := testpoo(t, BalanceRoundRobin, len(tc.hea))
+
+			for j, w := range p.wor {
+				w.hea = tc.hea[j]
+			}
+
+			var skp *worker
+			if tc.skp != -1 {
+				skp = p.wor[tc.skp]
+			}
+
+			_, err := p.pick(skp)
+			if !IsNoWorker(err) {
+				t.Fatalf("expected no worker error, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_Loader_Pool_Pick_RoundRobin(t *testing.T) {
+	p := testpoo(t, BalanceRoundRobin, 3)
+
+	{
+		cnt := map[int]int{}
+		lst := -1
+		for i := 0; i < 6; i++ {
+			w, err := p.pick(nil)
+			if err != nil {
+				t.Fatalf("expected pick to succeed, got %#v", err)
+			}
+
+			if w.idx == lst {
+				t.Fatalf("expected worker %d not to be picked twice in a row", lst)
+			}
+
+			cnt[w.idx]++
+			lst = w.idx
+		}
+
+		for i := 0; i < 3; i++ {
+			if cnt[i] != 2 {
+				t.Fatalf("expected every worker to be picked twice, got %v", cnt)
+			}
+		}
+	}
+
+	{
+		for i := 0; i < 6; i++ {
+			w, err := p.pick(p.wor[0])
+			if err != nil {
+				t.Fatalf("expected pick to succeed, got %#v", err)
+			}
+
+			if w.idx == 0 {
+				t.Fatalf("expected skipped worker not to be picked")
+			}
+		}
+	}
+
+	{
+		p.wor[1].hea = false
+		p.wor[2].hea = false
+
+		w, err := p.pick(p.wor[0])
+		if err != nil {
+			t.Fatalf("expected pick to succeed, got %#v", err)
+		}
+
+		if w.idx != 0 {
+			t.Fatalf("expected the only worker in rotation to be picked, got %d", w.idx)
+		}
+	}
+}
+
+func Test_Loader_Pool_Replace(t *testing.T) {
+	p := testpoo(t, BalanceRoundRobin, 2)
+
+	// The worker to be replaced cannot be restored, so that it stays out of
+	// rotation until the pool gets shut down.
+	rep := make(chan error, 100)
+	{
+		p.Con = func(l *Loader) { l.Nam = "in" + string(filepath.Separator) + "valid" }
+		p.Rep = func(err error) {
+			select {
+			case rep <- err:
+			default:
+			}
+		}
+	}
+
+	{
+		p.ctx, p.can = context.WithCancel(context.Background())
+		p.wor[0].ldr.chi = testexi(t)
+	}
+
+	// The next worker in turn is the one whose child process exited.
+	p.nxt = 1
+
+	var ldr []*Loader
+	err := p.call(context.Background(), func(l *Loader) error {
+		ldr = append(ldr, l)
+		if len(ldr) == 1 {
+			return tracer.Mask(childExitedError)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected call to succeed, got %#v", err)
+	}
+
+	if len(ldr) != 2 || ldr[0] != p.wor[0].ldr || ldr[1] != p.wor[1].ldr {
+		t.Fatalf("expected retry on the second worker")
+	}
+
+	{
+		p.mut.RLock()
+		hea := p.wor[0].hea
+		p.mut.RUnlock()
+
+		if hea {
+			t.Fatalf("expected worker to be taken out of rotation")
+		}
+	}
+
+	// Restoring the new worker is attempted in the background.
+	select {
+	case err := <-rep:
+		if !IsInvalidConfig(err) {
+			t.Fatalf("expected invalid config error, got %#v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expected worker to be restored in the background")
+	}
+
+	for i := 0; i < 4; i++ {
+		w, err := p.pick(nil)
+		if err != nil {
+			t.Fatalf("expected pick to succeed, got %#v", err)
+		}
+
+		if w.idx != 1 {
+			t.Fatalf("expected worker 1, got %d", w.idx)
+		}
+	}
+
+	{
+		p.mut.Lock()
+		p.wor[1].hea = false
+		p.mut.Unlock()
+	}
+
+	{
+		_, err := p.pick(nil)
+		if !IsNoWorker(err) {
+			t.Fatalf("expected no worker error, got %#v", err)
+		}
+	}
+
+	// Shutting down the pool stops replacing workers.
+	{
+		err := p.Sigkill()
+		if err != nil {
+			t.Fatalf("expected sigkill to succeed, got %#v", err)
+		}
+	}
+
+	if p.wor != nil {
+		t.Fatalf("expected no workers, got %d", len(p.wor))
+	}
+}
+
+func Test_Loader_Pool_Watch(t *testing.T) {
+	p := testpoo(t, BalanceRoundRobin, 2)
+
+	{
+		p.Con = func(l *Loader) { l.Nam = "in" + string(filepath.Separator) + "valid" }
+		p.ctx, p.can = context.WithCancel(context.Background())
+		p.wor[0].ldr.chi = testexi(t)
+	}
+
+	// Idle workers whose child process exited are replaced, too.
+	{
+		p.mut.Lock()
+		p.watch(p.ctx, p.wor[0])
+		p.watch(p.ctx, p.wor[1])
+		p.mut.Unlock()
+	}
+
+	for i := 0; ; i++ {
+		p.mut.RLock()
+		hea := p.wor[0].hea
+		p.mut.RUnlock()
+
+		if !hea {
+			break
+		}
+
+		if i == 300 {
+			t.Fatalf("expected worker to be taken out of rotation")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	{
+		p.mut.RLock()
+		hea := p.wor[1].hea
+		p.mut.RUnlock()
+
+		if !hea {
+			t.Fatalf("expected worker to stay in rotation")
+		}
+	}
+
+	{
+		err := p.Sigkill()
+		if err != nil {
+			t.Fatalf("expected sigkill to succeed, got %#v", err)
+		}
+	}
+}
+
+// testexi returns a child process which already exited, without requiring
+// Python, by executing the test binary without running any tests.
+func testexi(t *testing.T) *runner.Child {
+	t.Helper()
+
+	run := runner.Runner{
+		Mar: filepath.Join(t.TempDir(), "test.pat"),
+		Nam: "test",
+		Pyt: python.Config{Bin: os.Args[0], Ski: true},
+		Set: func(cmd *exec.Cmd) error {
+			cmd.Args = []string{os.Args[0], "-test.run=^$"}
+			return nil
+		},
+	}
+
+	chi, err := run.Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	{
+		<-chi.Done()
+	}
+
+	t.Cleanup(func() {
+		_ = chi.Remove()
+	})
+
+	return chi
+}
+
+// testpoo returns a pool with the given number of workers in rotation, the
+// loaders of which are not restored.
+func testpoo(t *testing.T, bal string, num int) *Pool {
+	t.Helper()
+
+	p := &Pool{
+		Bal: bal,
+		Buc: []string{"a"},
+		Buf: []string{"f"},
+		Num: num,
+		Pat: t.TempDir(),
+	}
+
+	for i := 0; i < num; i++ {
+		p.wor = append(p.wor, &worker{hea: true, idx: i, ldr: p.loader(i)})
+	}
+
+	return p
+}
+
@@ -0,0 +1,232 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xh3b4sd/xgboost/runner"
+)
+
+const (
+	// supmin is the initial backoff between restarts of a supervised child
+	// process.
+	supmin = 100 * time.Millisecond
+	// supmax is the maximum backoff between restarts of a supervised child
+	// process.
+	supmax = 30 * time.Second
+	// supgra is the grace period a failed request waits for the supervisor to
+	// notice that the child process went away, before the request failure is
+	// considered to be unrelated to the child process.
+	supgra = 3 * time.Second
+)
+
+// Status describes the supervised child process of a loader.
+type Status struct {
+	// Exi is the exit state of the child process that exited last, or nil if
+	// no supervised child process exited yet.
+	Exi *os.ProcessState
+	// Hea is whether the current child process is ready to serve predictions.
+	Hea bool
+	// Res is the number of successful restarts since Restore.
+	Res int
+}
+
+type supervisor struct {
+	// cha is closed and replaced on every state change, so that waiting
+	// requests can reevaluate the state.
+	cha chan struct{}
+	can context.CancelFunc
+	ctx context.Context
+	don chan struct{}
+	// gen is incremented whenever a restarted child process becomes ready.
+	gen int
+	hea bool
+	mut sync.Mutex
+	res int
+	sta *os.ProcessState
+}
+
+func newsup() *supervisor {
+	ctx, can := context.WithCancel(context.Background())
+
+	s := &supervisor{
+		cha: make(chan struct{}),
+		can: can,
+		ctx: ctx,
+		don: make(chan struct{}),
+		hea: true,
+	}
+
+	return s
+}
+
+// Status returns the state of the supervised child process. The zero value is
+// returned if Sup is not set.
+func (l *Loader) Status() Status {
+	l.mut.RLock()
+	sup := l.sup
+	l.mut.RUnlock()
+
+	if sup == nil {
+		return Status{}
+	}
+
+	sup.mut.Lock()
+	defer sup.mut.Unlock()
+
+	return Status{
+		Exi: sup.sta,
+		Hea: sup.hea,
+		Res: sup.res,
+	}
+}
+
+// supervise waits for the current child process to exit and restarts it until
+// the given supervisor gets stopped.
+func (l *Loader) supervise(sup *supervisor, chi *runner.Child) {
+	defer close(sup.don)
+
+	for {
+		select {
+		case <-chi.Done():
+		case <-sup.ctx.Done():
+			return
+		}
+
+		// Reading the exit state is safe once the child process is done, which
+		// is why it is read from the child process we waited for, instead of
+		// l.Cmd, which gets replaced concurrently.
+		exi := chi.Cmd.ProcessState
+		sup.update(func() {
+			sup.hea = false
+			sup.sta = exi
+		})
+
+		bac := supmin
+		for {
+			var err error
+			chi, err = l.restore(sup.ctx)
+			if err == nil {
+				break
+			}
+
+			if sup.ctx.Err() != nil {
+				return
+			}
+
+			l.report(err)
+
+			// A failed restart may have left an exited child process behind,
+			// whose exit state is the most recent one.
+			var cur *runner.Child
+			{
+				l.mut.RLock()
+				cur = l.chi
+				l.mut.RUnlock()
+			}
+
+			if cur != nil {
+				select {
+				case <-cur.Done():
+					exi := cur.Cmd.ProcessState
+					sup.update(func() {
+						sup.sta = exi
+					})
+				default:
+				}
+			}
+
+			select {
+			case <-time.After(bac):
+			case <-sup.ctx.Done():
+				return
+			}
+
+			bac *= 2
+			if bac > supmax {
+				bac = supmax
+			}
+		}
+
+		{
+			l.watch(chi)
+		}
+
+		sup.update(func() {
+			sup.gen++
+			sup.hea = true
+			sup.res++
+		})
+	}
+}
+
+// await waits for a child process newer than the given generation to become
+// ready. False is returned if the current child process did not go away within
+// the grace period, in which case there is nothing to wait for.
+func (s *supervisor) await(ctx context.Context, gen int) (bool, error) {
+	gra := time.After(supgra)
+
+	for {
+		var cha chan struct{}
+		var dea bool
+		{
+			s.mut.Lock()
+
+			if s.gen > gen && s.hea {
+				s.mut.Unlock()
+				return true, nil
+			}
+
+			cha = s.cha
+			dea = s.gen > gen || !s.hea
+
+			s.mut.Unlock()
+		}
+
+		select {
+		case <-cha:
+		case <-gra:
+			gra = nil
+			if !dea {
+				return false, nil
+			}
+		case <-s.ctx.Done():
+			return false, nil
+		case <-ctx.Done():
+			return false, ctxerr(ctx)
+		}
+	}
+}
+
+func (s *supervisor) generation() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.gen
+}
+
+// stop stops supervising and waits for any ongoing restart to finish.
+func (s *supervisor) stop() {
+	s.can()
+	<-s.don
+}
+
+// update applies the given state change and wakes up all waiting requests.
+func (s *supervisor) update(fun func()) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	fun()
+
+	close(s.cha)
+	s.cha = make(chan struct{})
+}
+
+// retryable returns whether the given request failure may be caused by the
+// child process going away, as opposed to the request itself being invalid or
+// given up on.
+func retryable(err error) bool {
+	return !IsCanceled(err) && !IsDeadlineExceeded(err) && !IsInvalidInput(err) && !IsRequestFailed(err)
+}
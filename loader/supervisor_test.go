@@ -0,0 +1,262 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+func Test_Loader_Supervisor_Await_Canceled(t *testing.T) {
+	testCases := []struct {
+		ctx func() (context.Context, context.CancelFunc)
+		mat func(error) bool
+	}{
+		// Case 0
+		{
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			mat: IsCanceled,
+		},
+		// Case 1
+		{
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			mat: IsDeadlineExceeded,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			s := newsup()
+
+			// The child process went away, so the request would wait for the
+			// restart, if it were not given up on.
+			s.update(func() {
+				s.hea = false
+			})
+
+			ctx, can := tc.ctx()
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				can()
+			}()
+
+			ok, err := s.await(ctx, 0)
+			if !tc.mat(err) {
+				t.Fatalf("expected matching error, got %#v", err)
+			}
+
+			if ok {
+				t.Fatalf("expected no restart")
+			}
+		})
+	}
+}
+
+func Test_Loader_Supervisor_Await_Generation(t *testing.T) {
+	s := newsup()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.update(func() {
+			s.hea = false
+		})
+
+		time.Sleep(20 * time.Millisecond)
+		s.update(func() {
+			s.gen++
+			s.hea = true
+		})
+	}()
+
+	{
+		ok, err := s.await(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if !ok {
+			t.Fatalf("expected restart")
+		}
+
+		if s.generation() != 1 {
+			t.Fatalf("expected generation 1, got %d", s.generation())
+		}
+	}
+
+	// A request failing before the restart returns immediately once the
+	// restarted child process is ready.
+	{
+		ok, err := s.await(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if !ok {
+			t.Fatalf("expected restart")
+		}
+	}
+
+	// A restarted child process which is not ready yet is waited for.
+	{
+		s.update(func() {
+			s.hea = false
+			s.gen++
+		})
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			s.update(func() {
+				s.hea = true
+			})
+		}()
+
+		ok, err := s.await(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if !ok {
+			t.Fatalf("expected restart")
+		}
+	}
+}
+
+func Test_Loader_Supervisor_Await_Grace(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		t.Parallel()
+
+		s := newsup()
+
+		// The child process never goes away, so the request failure is
+		// unrelated to the child process once the grace period is over.
+		sta := time.Now()
+
+		ok, err := s.await(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if ok {
+			t.Fatalf("expected no restart")
+		}
+
+		if time.Since(sta) < supgra {
+			t.Fatalf("expected await to wait for %s, got %s", supgra, time.Since(sta))
+		}
+	})
+
+	t.Run("restarting", func(t *testing.T) {
+		t.Parallel()
+
+		s := newsup()
+
+		// The child process went away within the grace period, so the
+		// restart is waited for, even if it takes longer than the grace
+		// period.
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			s.update(func() {
+				s.hea = false
+			})
+
+			time.Sleep(supgra)
+			s.update(func() {
+				s.gen++
+				s.hea = true
+			})
+		}()
+
+		ok, err := s.await(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if !ok {
+			t.Fatalf("expected restart")
+		}
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		t.Parallel()
+
+		s := newsup()
+
+		s.update(func() {
+			s.hea = false
+		})
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			s.can()
+		}()
+
+		ok, err := s.await(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("expected await to succeed, got %#v", err)
+		}
+
+		if ok {
+			t.Fatalf("expected no restart")
+		}
+	})
+}
+
+func Test_Loader_Supervisor_Retryable(t *testing.T) {
+	testCases := []struct {
+		err error
+		ret bool
+	}{
+		// Case 0
+		{
+			err: tracer.Mask(canceledError),
+			ret: false,
+		},
+		// Case 1
+		{
+			err: tracer.Mask(deadlineExceededError),
+			ret: false,
+		},
+		// Case 2
+		{
+			err: tracer.Mask(invalidInputError),
+			ret: false,
+		},
+		// Case 3
+		{
+			err: tracer.Maskf(requestFailedError, "bucket a failed"),
+			ret: false,
+		},
+		// Case 4
+		{
+			err: tracer.Mask(childExitedError),
+			ret: true,
+		},
+		// Case 5
+		{
+			err: tracer.Mask(invalidResponseError),
+			ret: true,
+		},
+		// Case 6, where connection errors are caused by the child process
+		// going away.
+		{
+			err: tracer.Mask(errors.New("connection refused")),
+			ret: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			ret := retryable(tc.err)
+			if ret != tc.ret {
+				t.Fatalf("expected %t, got %t", tc.ret, ret)
+			}
+		})
+	}
+}
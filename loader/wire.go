@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	conbin = "application/octet-stream"
+	conjsn = "application/json"
+)
+
+// encbin encodes the given inputs in the binary wire format, with all
+// integers and floats encoded in little endian byte order. The feature
+// vectors of every row are concatenated in the sorted order of the given
+// buffer hashes.
+//
+//     uint32    number of rows
+//     uint32    number of buffers
+//     []uint32  number of features per buffer
+//     []float32 features, row by row
+//
+func encbin(buf []string, inp []map[string][]float32) ([]byte, error) {
+	buf = append([]string{}, buf...)
+	sort.Strings(buf)
+
+	siz := make([]int, len(buf))
+	for i, b := range buf {
+		siz[i] = len(inp[0][b])
+	}
+
+	var col int
+	for i, b := range buf {
+		if siz[i] == 0 {
+			return nil, tracer.Maskf(invalidInputError, "features for buffer %q must not be empty", b)
+		}
+
+		col += siz[i]
+	}
+
+	byt := make([]byte, 8+4*len(buf)+4*col*len(inp))
+	off := 0
+
+	{
+		binary.LittleEndian.PutUint32(byt[off:], uint32(len(inp)))
+		off += 4
+		binary.LittleEndian.PutUint32(byt[off:], uint32(len(buf)))
+		off += 4
+	}
+
+	for _, s := range siz {
+		binary.LittleEndian.PutUint32(byt[off:], uint32(s))
+		off += 4
+	}
+
+	for r, x := range inp {
+		for i, b := range buf {
+			if len(x[b]) != siz[i] {
+				return nil, tracer.Maskf(invalidInputError, "row %d must have %d features for buffer %q", r, siz[i], b)
+			}
+
+			for _, f := range x[b] {
+				binary.LittleEndian.PutUint32(byt[off:], math.Float32bits(f))
+				off += 4
+			}
+		}
+	}
+
+	return byt, nil
+}
+
+// decbin decodes the given predictions in the binary wire format, which are
+// raw little endian float32 values, one per row.
+func decbin(byt []byte, row int) ([]float32, error) {
+	if len(byt) != 4*row {
+		return nil, tracer.Maskf(invalidResponseError, "expected %d bytes, got %d", 4*row, len(byt))
+	}
+
+	pre := make([]float32, row)
+	for i := range pre {
+		pre[i] = math.Float32frombits(binary.LittleEndian.Uint32(byt[4*i:]))
+	}
+
+	return pre, nil
+}
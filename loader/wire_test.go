@@ -0,0 +1,247 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func Test_Loader_Wire_Decbin(t *testing.T) {
+	testCases := []struct {
+		byt []byte
+		row int
+		pre []float32
+	}{
+		// Case 0
+		{
+			byt: testwir(float32(0.5)),
+			row: 1,
+			pre: []float32{0.5},
+		},
+		// Case 1
+		{
+			byt: testwir(float32(0.25), float32(-1), float32(3)),
+			row: 3,
+			pre: []float32{0.25, -1, 3},
+		},
+		// Case 2
+		{
+			byt: []byte{},
+			row: 0,
+			pre: []float32{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			pre, err := decbin(tc.byt, tc.row)
+			if err != nil {
+				t.Fatalf("expected decbin to succeed, got %#v", err)
+			}
+
+			if len(pre) != len(tc.pre) {
+				t.Fatalf("expected %v, got %v", tc.pre, pre)
+			}
+
+			for j := range tc.pre {
+				if pre[j] != tc.pre[j] {
+					t.Fatalf("expected %v, got %v", tc.pre, pre)
+				}
+			}
+		})
+	}
+}
+
+func Test_Loader_Wire_Decbin_Invalid(t *testing.T) {
+	testCases := []struct {
+		byt []byte
+		row int
+	}{
+		// Case 0, where fewer predictions than rows are given.
+		{
+			byt: testwir(float32(0.5)),
+			row: 2,
+		},
+		// Case 1, where more predictions than rows are given.
+		{
+			byt: testwir(float32(0.5), float32(0.5)),
+			row: 1,
+		},
+		// Case 2, where a prediction is truncated.
+		{
+			byt: testwir(float32(0.5))[:3],
+			row: 1,
+		},
+		// Case 3
+		{
+			byt: []byte{},
+			row: 1,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			_, err := decbin(tc.byt, tc.row)
+			if !IsInvalidResponse(err) {
+				t.Fatalf("expected invalid response error, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_Loader_Wire_Encbin(t *testing.T) {
+	testCases := []struct {
+		buf []string
+		inp []map[string][]float32
+		byt []byte
+	}{
+		// Case 0
+		{
+			buf: []string{"f"},
+			inp: []map[string][]float32{
+				{"f": {1, 2}},
+			},
+			byt: testwir(
+				uint32(1), uint32(1),
+				uint32(2),
+				float32(1), float32(2),
+			),
+		},
+		// Case 1, where buffers are encoded in sorted order with their own
+		// sizes, no matter the order they are configured in.
+		{
+			buf: []string{"g", "f"},
+			inp: []map[string][]float32{
+				{"f": {1}, "g": {2, 3, 4}},
+			},
+			byt: testwir(
+				uint32(1), uint32(2),
+				uint32(1), uint32(3),
+				float32(1), float32(2), float32(3), float32(4),
+			),
+		},
+		// Case 2, where rows are concatenated row by row.
+		{
+			buf: []string{"g", "f"},
+			inp: []map[string][]float32{
+				{"f": {1, 2}, "g": {3}},
+				{"f": {4, 5}, "g": {6}},
+				{"f": {7, 8}, "g": {float32(math.Inf(1))}},
+			},
+			byt: testwir(
+				uint32(3), uint32(2),
+				uint32(2), uint32(1),
+				float32(1), float32(2), float32(3),
+				float32(4), float32(5), float32(6),
+				float32(7), float32(8), float32(math.Inf(1)),
+			),
+		},
+		// Case 3, where inputs for buffers not configured are ignored.
+		{
+			buf: []string{"f"},
+			inp: []map[string][]float32{
+				{"f": {1}, "g": {2}},
+			},
+			byt: testwir(
+				uint32(1), uint32(1),
+				uint32(1),
+				float32(1),
+			),
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			buf := append([]string{}, tc.buf...)
+
+			byt, err := encbin(tc.buf, tc.inp)
+			if err != nil {
+				t.Fatalf("expected encbin to succeed, got %#v", err)
+			}
+
+			if !bytes.Equal(byt, tc.byt) {
+				t.Fatalf("expected %v, got %v", tc.byt, byt)
+			}
+
+			for j := range buf {
+				if tc.buf[j] != buf[j] {
+					t.Fatalf("expected buffers %v to remain unsorted, got %v", buf, tc.buf)
+				}
+			}
+		})
+	}
+}
+
+func Test_Loader_Wire_Encbin_Invalid(t *testing.T) {
+	testCases := []struct {
+		buf []string
+		inp []map[string][]float32
+	}{
+		// Case 0, where a later row has fewer features.
+		{
+			buf: []string{"f"},
+			inp: []map[string][]float32{
+				{"f": {1, 2}},
+				{"f": {3}},
+			},
+		},
+		// Case 1, where a later row has more features.
+		{
+			buf: []string{"f", "g"},
+			inp: []map[string][]float32{
+				{"f": {1}, "g": {2}},
+				{"f": {3}, "g": {4, 5}},
+			},
+		},
+		// Case 2, where a later row misses a buffer.
+		{
+			buf: []string{"f", "g"},
+			inp: []map[string][]float32{
+				{"f": {1}, "g": {2}},
+				{"f": {3}},
+			},
+		},
+		// Case 3, where the first row misses a buffer.
+		{
+			buf: []string{"f", "g"},
+			inp: []map[string][]float32{
+				{"f": {1}},
+			},
+		},
+		// Case 4
+		{
+			buf: []string{"f"},
+			inp: []map[string][]float32{
+				{"f": {}},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			_, err := encbin(tc.buf, tc.inp)
+			if !IsInvalidInput(err) {
+				t.Fatalf("expected invalid input error, got %#v", err)
+			}
+		})
+	}
+}
+
+// testwir returns the given uint32 and float32 values in little endian byte
+// order, the way the binary wire format encodes them.
+func testwir(val ...interface{}) []byte {
+	byt := make([]byte, 4*len(val))
+
+	for i, v := range val {
+		switch v := v.(type) {
+		case uint32:
+			binary.LittleEndian.PutUint32(byt[4*i:], v)
+		case float32:
+			binary.LittleEndian.PutUint32(byt[4*i:], math.Float32bits(v))
+		}
+	}
+
+	return byt
+}
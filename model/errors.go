@@ -0,0 +1,55 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost/runner"
+)
+
+var canceledError = &tracer.Error{
+	Kind: "canceledError",
+}
+
+func IsCanceled(err error) bool {
+	return errors.Is(err, canceledError)
+}
+
+var childExitedError = &tracer.Error{
+	Kind: "childExitedError",
+}
+
+func IsChildExited(err error) bool {
+	return errors.Is(err, childExitedError)
+}
+
+var deadlineExceededError = &tracer.Error{
+	Kind: "deadlineExceededError",
+}
+
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, deadlineExceededError)
+}
+
+func IsInUse(err error) bool {
+	return runner.IsInUse(err)
+}
+
+var invalidConfigError = &tracer.Error{
+	Kind: "invalidConfigError",
+}
+
+func IsInvalidConfig(err error) bool {
+	return errors.Is(err, invalidConfigError)
+}
+
+// ctxerr translates the error of the given, already finished context into
+// one of the typed errors of this package.
+func ctxerr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return tracer.Maskf(deadlineExceededError, "%s", ctx.Err())
+	}
+
+	return tracer.Maskf(canceledError, "%s", ctx.Err())
+}
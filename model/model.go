@@ -1,15 +1,18 @@
 package model
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost"
+	"github.com/xh3b4sd/xgboost/python"
+	"github.com/xh3b4sd/xgboost/runner"
 )
 
 type Model struct {
@@ -19,10 +22,32 @@ type Model struct {
 	Buf string
 	Cmd *exec.Cmd
 	Deb bool
+	// Ens optionally configures the hyperparameters of the ensemble trained on
+	// top of the bucket models in order to evaluate them. Zero values fall back
+	// to the defaults of the default template.
+	Ens xgboost.Params
 	Fil *os.File
+	// Hoo contains optional hooks for capturing the output of the child
+	// process, classifying its exit and retaining its temp file.
+	Hoo runner.Hooks
+	// Lab optionally configures how raw labels are transformed into the
+	// targets the ensemble is trained and evaluated on. Bucket models are
+	// trained on the raw labels unless Lab uses xgboost.LabelClassMap.
+	// Defaults to xgboost.LabelThreeWay.
+	Lab xgboost.Labels
 	// Log is the required maximum logarithmic error a trained model must not
 	// exceed in order to be considered valid.
 	Log float32
+	// Nor optionally configures how probabilities are normalized, which must
+	// be the same for training and serving. Defaults to
+	// xgboost.NormalizeThreshold. The normalization is recorded in
+	// "<Pat>/<Buf>/mod/nor.json" whenever models get saved.
+	Nor xgboost.Normalize
+	// Par optionally configures the hyperparameters of the bucket models. Zero
+	// values fall back to the defaults of the default template. The effective
+	// parameters are recorded in "<Pat>/<Buf>/mod/par.json" whenever models get
+	// saved.
+	Par xgboost.Params
 	// Pat is the required data path in which the data of the trained model will
 	// be put in.
 	//
@@ -41,168 +66,324 @@ type Model struct {
 	//     └── ensemble.ubj
 	//
 	Pat string
+	// Pde enables the parent death signal on Linux, which kills the child
+	// process once the current process exits, even if the current process
+	// got killed itself without any chance to clean up.
+	Pde bool
+	// Pro is optionally called with every progress event while training, e.g.
+	// after every boosting round. Pro is called sequentially from a separate
+	// goroutine.
+	//
+	//     Pro: func(pro xgboost.Progress) {
+	//         if pro.Kin == xgboost.ProgressRound {
+	//             fmt.Printf("%s round %d logloss %f\n", pro.Boo, pro.Rou, pro.Val["logloss"])
+	//         }
+	//     },
+	//
+	Pro func(xgboost.Progress)
+	// Pyt optionally configures the Python interpreter, its environment and
+	// the resource limits of the child process.
+	Pyt python.Config
+	// Res is the result of the last successful training run, as read from
+	// res.json once Train succeeded.
+	Res *xgboost.TrainResult
 	// Tem is the required Python script template that is first being rendered
 	// and persisted, and then executed in a child process.
 	Tem string
 	// Upd requires a model to exist in order for it to continue training on the
 	// prepared data set.
 	Upd bool
+	// Wai enables waiting for another process to release its lock on Pat,
+	// instead of failing right away with an in use error.
+	Wai bool
 }
 
 func (m *Model) Execute() ([]byte, error) {
 	{
-		m.configs()
-	}
-
-	var buf bytes.Buffer
-	{
-		t, err := template.New("model").Parse(m.Tem)
+		err := m.configs()
 		if err != nil {
 			return nil, tracer.Mask(err)
 		}
+	}
 
-		err = t.Execute(&buf, m.mapping())
-		if err != nil {
-			return nil, tracer.Mask(err)
-		}
+	byt, err := runner.Render("model", m.Tem, m.mapping())
+	if err != nil {
+		return nil, tracer.Mask(err)
 	}
 
-	return buf.Bytes(), nil
+	return byt, nil
 }
 
 func (m *Model) Train() error {
-	var err error
+	return m.TrainContext(context.Background())
+}
 
+// TrainContext works like Train but kills the child process group once the
+// given context gets canceled, in which case a deadline or cancelation error
+// is returned.
+func (m *Model) TrainContext(ctx context.Context) (err error) {
 	{
-		m.configs()
-		m.cleanup()
+		err = m.configs()
+		if err != nil {
+			return tracer.Mask(err)
+		}
 	}
 
-	var byt []byte
 	{
-		byt, err = m.Execute()
+		err = m.Pyt.Check(ctx, python.Training)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	// Other processes training with the same Pat are locked out, so that
+	// nobody removes the temp files of anybody else.
+	var loc *os.File
 	{
-		m.Fil, err = os.CreateTemp("", "xgboost-model-template-*")
+		loc, err = runner.Lock(ctx, m.locfilp(), m.Wai)
+		if err != nil && ctx.Err() != nil {
+			return ctxerr(ctx)
+		} else if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	defer runner.Unlock(loc)
+
+	{
+		err = m.cleanup()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	// The temp files of the child process are cleaned up once training is
+	// over, no matter whether the child process succeeded or not.
+	defer func() {
+		cle := m.cleanup()
+		if err == nil && cle != nil {
+			err = tracer.Mask(cle)
+		}
+	}()
+
+	var byt []byte
 	{
-		_, err := m.Fil.Write(byt)
+		byt, err = m.Execute()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	var chi *runner.Child
 	{
-		err := m.Fil.Close()
+		chi, err = m.runner().Start(byt)
 		if err != nil {
 			return tracer.Mask(err)
 		}
+
+		m.Cmd = chi.Cmd
+		m.Fil = chi.Fil
+	}
+
+	select {
+	case <-chi.Done():
+		err := chi.Err()
+		if err != nil {
+			return tracer.Maskf(childExitedError, "%s", err)
+		}
+	case <-ctx.Done():
+		{
+			err := chi.Kill()
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		{
+			<-chi.Done()
+		}
+
+		return ctxerr(ctx)
 	}
 
 	{
-		err = ioutil.WriteFile(m.temfilp(), m.temfilb(), 0664)
+		m.Res, err = m.Result()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
+	return nil
+}
+
+// Result returns the result of the last training run as written to res.json
+// within Pat, regardless of whether the training run happened within the
+// current process.
+func (m *Model) Result() (*xgboost.TrainResult, error) {
 	{
-		m.Cmd = exec.Command("python3", m.Fil.Name())
+		err := m.configs()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
 	}
 
-	if m.Deb {
-		m.Cmd.Stdout = os.Stdout
-		m.Cmd.Stderr = os.Stderr
+	byt, err := ioutil.ReadFile(m.resfilp())
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var res xgboost.TrainResult
+	err = json.Unmarshal(byt, &res)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return &res, nil
+}
+
+// Validate returns an invalid config error if any of the required fields is
+// not set, or if any of the nested configurations is invalid.
+func (m *Model) Validate() error {
+	if len(m.Buc) == 0 {
+		return tracer.Maskf(invalidConfigError, "Model.Buc must not be empty")
+	}
+
+	if m.Buf == "" {
+		return tracer.Maskf(invalidConfigError, "Model.Buf must not be empty")
+	}
+
+	if m.Log == 0 {
+		return tracer.Maskf(invalidConfigError, "Model.Log must not be empty")
+	}
+
+	if m.Pat == "" {
+		return tracer.Maskf(invalidConfigError, "Model.Pat must not be empty")
 	}
 
 	{
-		err := m.Cmd.Start()
+		err := m.Lab.Validate()
 		if err != nil {
-			return tracer.Mask(err)
+			return tracer.Maskf(invalidConfigError, "Model.Lab is invalid, %s", err)
 		}
 	}
 
 	{
-		err := m.Cmd.Wait()
+		err := m.Ens.Validate()
 		if err != nil {
-			return tracer.Mask(err)
+			return tracer.Maskf(invalidConfigError, "Model.Ens is invalid, %s", err)
 		}
 	}
 
 	{
-		m.cleanup()
+		err := m.Nor.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Model.Nor is invalid, %s", err)
+		}
 	}
 
-	return nil
-}
-
-func (m *Model) cleanup() {
-	if exists(m.temfilp()) {
-		if exists(m.temfilc()) {
-			err := os.Remove(m.temfilc())
-			if err != nil {
-				panic(err)
-			}
+	{
+		err := m.Par.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Model.Par is invalid, %s", err)
 		}
+	}
 
-		err := os.Remove(m.temfilp())
+	{
+		err := m.Lab.ValidateObjective(m.Ens.Fill(defens).Objective())
 		if err != nil {
-			panic(err)
+			return tracer.Maskf(invalidConfigError, "Model.Ens is invalid for Model.Lab, %s", err)
 		}
 	}
-}
 
-func (m *Model) configs() {
-	if len(m.Buc) == 0 {
-		panic("Model.Buc must not be empty")
+	{
+		err := m.Lab.ValidateObjective(m.Par.Fill(defmod).Objective())
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Model.Par is invalid for Model.Lab, %s", err)
+		}
 	}
 
-	if m.Buf == "" {
-		panic("Model.Buf must not be empty")
+	{
+		err := m.Pyt.Validate()
+		if err != nil {
+			return tracer.Maskf(invalidConfigError, "Model.Pyt is invalid, %s", err)
+		}
 	}
 
-	if m.Log == 0 {
-		panic("Model.Log must not be empty")
+	return nil
+}
+
+func (m *Model) cleanup() error {
+	err := m.runner().Cleanup()
+	if err != nil {
+		return tracer.Mask(err)
 	}
 
-	if m.Pat == "" {
-		panic("Model.Pat must not be empty")
+	return nil
+}
+
+func (m *Model) configs() error {
+	{
+		err := m.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
 	}
 
 	if m.Tem == "" {
 		m.Tem = deftem
 	}
+
+	return nil
+}
+
+func (m *Model) locfilp() string {
+	return filepath.Join(m.Pat, m.Buf, "model.lock")
 }
 
 func (m *Model) mapping() map[string]interface{} {
 	return map[string]interface{}{
 		"Buc": m.Buc,
 		"Buf": m.Buf,
+		"Cla": m.Lab.Pre == xgboost.LabelClassMap,
+		"Ens": runner.Literal(m.Ens.Fill(defens).Map()),
+		"Lab": runner.Literal(m.Lab.Map()),
 		"Log": m.Log,
+		"Nom": runner.Literal(m.Nor.Map()),
+		"Nor": m.Nor.Script(),
+		"Par": runner.Literal(m.Par.Fill(defmod).Map()),
 		"Pat": strings.TrimSuffix(m.Pat, "/"),
+		"Pro": m.Pro != nil,
 		"Upd": m.Upd,
 	}
 }
 
-func (m *Model) temfilb() []byte {
-	return []byte(m.Fil.Name())
+func (m *Model) resfilp() string {
+	return filepath.Join(m.Pat, m.Buf, "res", "res.json")
 }
 
-func (m *Model) temfilc() string {
-	byt, err := ioutil.ReadFile(m.temfilp())
-	if err != nil {
-		panic(err)
+func (m *Model) runner() runner.Runner {
+	hoo := m.Hoo
+	if m.Pro != nil {
+		lin := hoo.Lin
+		hoo.Lin = func(s string) bool {
+			pro, ok := xgboost.ParseProgress(s)
+			if ok {
+				m.Pro(pro)
+				return true
+			}
+
+			return lin != nil && lin(s)
+		}
 	}
 
-	return strings.TrimSpace(string(byt))
+	return runner.Runner{
+		Deb: m.Deb,
+		Hoo: hoo,
+		Mar: m.temfilp(),
+		Nam: "model",
+		Pde: m.Pde,
+		Pyt: m.Pyt,
+	}
 }
 
 func (m *Model) temfilp() string {
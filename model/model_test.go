@@ -0,0 +1,98 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xh3b4sd/xgboost"
+)
+
+// basmat is the function building the training matrices of the bucket models
+// as rendered before labels became configurable, which trains bucket models on
+// the raw labels.
+const basmat = `def build_model_matrix(path):
+  fea = []
+  lab = []
+
+  for p in path:
+    c = pd.read_csv(p, header=None)
+
+    f = c.copy().astype('float')
+    l = f.pop(0)
+
+    fea.append(f)
+    lab.append(l)
+
+  return xgb.DMatrix(pd.concat(fea, axis=0, ignore_index=True), pd.concat(lab, axis=0, ignore_index=True))
+`
+
+func Test_Model_Execute_Labels(t *testing.T) {
+	testCases := []struct {
+		lab xgboost.Labels
+		obj string
+		raw bool
+	}{
+		// Case 0, where the default labels keep the raw labels.
+		{
+			lab: xgboost.Labels{},
+			raw: true,
+		},
+		// Case 1
+		{
+			lab: xgboost.Labels{Pre: xgboost.LabelBinary, Thr: 0.5},
+			raw: true,
+		},
+		// Case 2
+		{
+			lab: xgboost.Labels{Pre: xgboost.LabelIdentity},
+			raw: true,
+		},
+		// Case 3, where class indices are required by multiclass objectives.
+		{
+			lab: xgboost.Labels{Pre: xgboost.LabelClassMap, Cla: map[float64]int{1: 0, 2: 1}},
+			obj: "multi:softprob",
+			raw: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			m := &Model{
+				Buc: []string{"a"},
+				Buf: "f",
+				Ens: xgboost.Params{Obj: tc.obj},
+				Lab: tc.lab,
+				Log: 0.1,
+				Par: xgboost.Params{Obj: tc.obj},
+				Pat: "/tmp/dat",
+			}
+
+			byt, err := m.Execute()
+			if err != nil {
+				t.Fatalf("expected execute to succeed, got %#v", err)
+			}
+
+			fun := function(string(byt), "build_model_matrix")
+			if (fun == basmat) != tc.raw {
+				t.Fatalf("expected raw labels to be %t, got\n%s", tc.raw, fun)
+			}
+		})
+	}
+}
+
+// function returns the Python function of the given name from the given
+// script, up to the next separator.
+func function(scr string, nam string) string {
+	i := strings.Index(scr, "def "+nam+"(")
+	if i == -1 {
+		return ""
+	}
+
+	j := strings.Index(scr[i:], "\n\n####")
+	if j == -1 {
+		return scr[i:]
+	}
+
+	return scr[i : i+j+1]
+}
@@ -0,0 +1,32 @@
+package model
+
+import "github.com/xh3b4sd/xgboost"
+
+// defens are the default parameters of the ensemble trained on top of the
+// bucket models in order to evaluate them.
+var defens = xgboost.Params{
+	Bas: 0.50,
+	Boo: "gbtree",
+	Ear: 25,
+	Eva: []string{"error", "logloss"},
+	Gam: 10.00,
+	Gro: "lossguide",
+	Lea: 0.02,
+	Max: 20,
+	Obj: "reg:logistic",
+	Rou: 5000,
+}
+
+// defmod are the default parameters of the bucket models.
+var defmod = xgboost.Params{
+	Bas: 0.01,
+	Boo: "gbtree",
+	Ear: 25,
+	Eva: []string{"error", "logloss"},
+	Gam: 10.00,
+	Gro: "lossguide",
+	Lea: 0.02,
+	Max: 20,
+	Obj: "reg:logistic",
+	Rou: 5000,
+}
@@ -0,0 +1,199 @@
+package xgboost
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	// NormalizeCalibrated maps probabilities to calibrated probabilities via
+	// Platt scaling, using Normalize.Sca and Normalize.Off.
+	//
+	//     1 / (1 + exp(Sca * p + Off))
+	//
+	NormalizeCalibrated = "calibrated"
+	// NormalizeRaw keeps probabilities as they are.
+	NormalizeRaw = "raw"
+	// NormalizeThreshold maps probabilities above Normalize.Hig to 1,
+	// probabilities below Normalize.Low to 0 and everything in between to 0.5.
+	NormalizeThreshold = "threshold"
+)
+
+// Normalize describes how probabilities are normalized, both the outputs of
+// bucket models before being fed into the ensemble and the final ensemble
+// prediction. The same value must be used for training and serving, which is
+// why the Python function rendered into all script templates as well as the
+// native implementation of the booster package are derived from it. The zero
+// value describes NormalizeThreshold with cut-offs at 0.15 and 0.85. Training
+// records the normalization next to the saved models in "nor.json", which
+// loaders check against their own configuration.
+//
+//     nor := xgboost.Normalize{
+//         Pre: xgboost.NormalizeThreshold,
+//         Hig: 0.7,
+//         Low: 0.3,
+//     }
+//
+type Normalize struct {
+	// Hig is the upper cut-off for NormalizeThreshold. Hig and Low default to
+	// 0.85 and 0.15 if both are 0.
+	Hig float64
+	// Low is the lower cut-off for NormalizeThreshold. Low can be set to 0
+	// explicitly as long as Hig is set.
+	Low float64
+	// Off is the offset of Platt scaling for NormalizeCalibrated.
+	Off float64
+	// Pre is the preset, e.g. NormalizeRaw. Defaults to NormalizeThreshold.
+	Pre string
+	// Sca is the scale of Platt scaling for NormalizeCalibrated, which is
+	// usually negative and must not be 0.
+	Sca float64
+}
+
+// Apply normalizes the given probability. NaN is returned as it is.
+func (n Normalize) Apply(x float32) float32 {
+	n = n.fill()
+
+	if math.IsNaN(float64(x)) {
+		return x
+	}
+
+	switch n.Pre {
+	case NormalizeCalibrated:
+		return float32(1 / (1 + math.Exp(n.Sca*float64(x)+n.Off)))
+	case NormalizeRaw:
+		return x
+	}
+
+	// Comparing in single precision matches numpy comparing float32 arrays
+	// against Python floats.
+	if x > float32(n.Hig) {
+		return 1
+	}
+
+	if x >= float32(n.Low) {
+		return 0.5
+	}
+
+	return 0
+}
+
+// Script returns the Python function "normalize" as rendered into the script
+// templates, which normalizes numpy arrays the same way as Apply.
+func (n Normalize) Script() string {
+	n = n.fill()
+
+	switch n.Pre {
+	case NormalizeCalibrated:
+		return fmt.Sprintf("def normalize(l):\n  return 1.0 / (1.0 + np.exp(%s * l + %s))\n", flo(n.Sca), flo(n.Off))
+	case NormalizeRaw:
+		return "def normalize(l):\n  return l\n"
+	}
+
+	hig := flo(n.Hig)
+	low := flo(n.Low)
+
+	// Every condition is evaluated against the original array, so that no
+	// branch sees values already normalized by another one. NaN matches none
+	// of the conditions and is returned as it is.
+	return "def normalize(l):\n" +
+		"  return np.select([l > " + hig + ", l >= " + low + ", l < " + low + "], [1, 0.5, 0], l)\n"
+}
+
+// Check returns an invalid normalize error if any of the given files, as
+// written next to the saved models during training, records a normalization
+// different from n. Files that do not exist are skipped, since models saved
+// before the normalization got recorded cannot be checked.
+func (n Normalize) Check(fil ...string) error {
+	var cur map[string]interface{}
+	{
+		byt, err := json.Marshal(n.Map())
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = json.Unmarshal(byt, &cur)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	for _, f := range fil {
+		byt, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return tracer.Mask(err)
+		}
+
+		var rec map[string]interface{}
+		err = json.Unmarshal(byt, &rec)
+		if err != nil {
+			return tracer.Maskf(invalidNormalizeError, "%s must contain a normalization, %s", f, err)
+		}
+
+		if !reflect.DeepEqual(cur, rec) {
+			return tracer.Maskf(invalidNormalizeError, "%s records a different normalization", f)
+		}
+	}
+
+	return nil
+}
+
+// Map returns the normalization as recorded next to the saved models, with
+// all defaults applied.
+func (n Normalize) Map() map[string]interface{} {
+	n = n.fill()
+
+	return map[string]interface{}{
+		"high":   n.Hig,
+		"low":    n.Low,
+		"offset": n.Off,
+		"preset": n.Pre,
+		"scale":  n.Sca,
+	}
+}
+
+// Validate returns an invalid normalize error if the preset is unknown or the
+// configured values are out of range.
+func (n Normalize) Validate() error {
+	if n.Pre != "" && n.Pre != NormalizeCalibrated && n.Pre != NormalizeRaw && n.Pre != NormalizeThreshold {
+		return tracer.Maskf(invalidNormalizeError, "Normalize.Pre must be %s, %s or %s, got %q", NormalizeCalibrated, NormalizeRaw, NormalizeThreshold, n.Pre)
+	}
+
+	if n.Pre == NormalizeCalibrated && n.Sca == 0 {
+		return tracer.Maskf(invalidNormalizeError, "Normalize.Sca must not be 0 for %s", NormalizeCalibrated)
+	}
+
+	f := n.fill()
+	if f.Low < 0 || f.Hig > 1 || f.Low > f.Hig {
+		return tracer.Maskf(invalidNormalizeError, "Normalize.Low and Normalize.Hig must satisfy 0 <= Low <= Hig <= 1")
+	}
+
+	return nil
+}
+
+func (n Normalize) fill() Normalize {
+	if n.Pre == "" {
+		n.Pre = NormalizeThreshold
+	}
+
+	if n.Hig == 0 && n.Low == 0 {
+		n.Hig = 0.85
+		n.Low = 0.15
+	}
+
+	return n
+}
+
+// flo formats the given float as Python literal.
+func flo(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,148 @@
+package xgboost
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Normalize_Apply_Low(t *testing.T) {
+	nor := Normalize{Hig: 0.4, Low: 0}
+
+	if nor.Apply(0) != 0.5 {
+		t.Fatalf("expected 0.5 for an explicit lower cut-off of 0, got %f", nor.Apply(0))
+	}
+
+	if nor.Apply(0.5) != 1 {
+		t.Fatalf("expected 1, got %f", nor.Apply(0.5))
+	}
+}
+
+func Test_Normalize_Check(t *testing.T) {
+	dir := t.TempDir()
+
+	// Python writes floats without trailing zeros and integral floats like
+	// the scale of 0 the same way Go marshals them.
+	mat := filepath.Join(dir, "mat.json")
+	{
+		err := ioutil.WriteFile(mat, []byte(`{"high": 0.85, "low": 0.15, "offset": 0, "preset": "threshold", "scale": 0}`+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dif := filepath.Join(dir, "dif.json")
+	{
+		err := ioutil.WriteFile(dif, []byte(`{"high": 0.9, "low": 0.6, "offset": 0, "preset": "threshold", "scale": 0}`+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		err := Normalize{}.Check(mat, filepath.Join(dir, "missing.json"))
+		if err != nil {
+			t.Fatalf("expected matching normalization to pass, got %#v", err)
+		}
+	}
+
+	{
+		err := Normalize{}.Check(mat, dif)
+		if !IsInvalidNormalize(err) {
+			t.Fatalf("expected invalid normalize error, got %#v", err)
+		}
+	}
+
+	{
+		err := Normalize{Hig: 0.9, Low: 0.6}.Check(dif)
+		if err != nil {
+			t.Fatalf("expected matching normalization to pass, got %#v", err)
+		}
+	}
+}
+
+// Test_Normalize_Script verifies that the rendered Python function normalizes
+// the same way as Apply, which is what keeps training and serving in sync.
+func Test_Normalize_Script(t *testing.T) {
+	{
+		err := exec.Command("python3", "-c", "import numpy").Run()
+		if err != nil {
+			t.Skip("python3 with numpy is not available")
+		}
+	}
+
+	inp := []float32{0, 0.1, 0.15, 0.3, 0.5, 0.6, 0.7, 0.85, 0.9, 0.95, 1, float32(math.NaN())}
+
+	testCases := []struct {
+		nor Normalize
+	}{
+		// Case 0
+		{
+			nor: Normalize{},
+		},
+		// Case 1, where the lower cut-off is above 0.5.
+		{
+			nor: Normalize{Hig: 0.9, Low: 0.6},
+		},
+		// Case 2
+		{
+			nor: Normalize{Hig: 0.4, Low: 0},
+		},
+		// Case 3
+		{
+			nor: Normalize{Pre: NormalizeRaw},
+		},
+		// Case 4
+		{
+			nor: Normalize{Off: 0.5, Pre: NormalizeCalibrated, Sca: -2},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			scr := "import sys\n" +
+				"import numpy as np\n" +
+				"\n" +
+				tc.nor.Script() +
+				"\n" +
+				"for v in normalize(np.asarray([float(a) for a in sys.argv[1:]], dtype=np.float32)):\n" +
+				"  print(float(v))\n"
+
+			arg := []string{"-c", scr}
+			for _, x := range inp {
+				arg = append(arg, strconv.FormatFloat(float64(x), 'g', -1, 32))
+			}
+
+			out, err := exec.Command("python3", arg...).CombinedOutput()
+			if err != nil {
+				t.Fatalf("expected script to succeed, got %s", out)
+			}
+
+			lin := strings.Fields(string(out))
+			if len(lin) != len(inp) {
+				t.Fatalf("expected %d values, got %q", len(inp), out)
+			}
+
+			for j, x := range inp {
+				pyt, err := strconv.ParseFloat(lin[j], 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				app := float64(tc.nor.Apply(x))
+				if math.IsNaN(app) && math.IsNaN(pyt) {
+					continue
+				}
+
+				if math.Abs(app-pyt) > 1e-6 {
+					t.Fatalf("expected %s for %v, got %v", fmt.Sprint(pyt), x, app)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package xgboost
+
+import (
+	"encoding/json"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// Params describes the hyperparameters of training a booster. Zero values
+// fall back to the defaults of the respective training script, which is why
+// setting a zero value explicitly, e.g. a gamma of 0, requires Ext.
+//
+//     par := xgboost.Params{
+//         Lea: 0.05,
+//         Max: 8,
+//         Ext: map[string]interface{}{
+//             "gamma":     0,
+//             "subsample": 0.8,
+//         },
+//     }
+//
+type Params struct {
+	// Bas is the initial prediction score of all instances, "base_score".
+	Bas float64
+	// Boo is the booster to use, either "gbtree", "gblinear" or "dart".
+	Boo string
+	// Ear is the number of boosting rounds without improvement on the
+	// validation set after which training stops early.
+	Ear int
+	// Eva contains the eval metrics computed on the training and validation
+	// set after every boosting round, "eval_metric".
+	Eva []string
+	// Ext contains any further XGBoost parameters not covered above, which
+	// are applied on top of all other parameters. Ext may also override
+	// "num_boost_round" and "early_stopping_rounds", where 0 disables early
+	// stopping.
+	Ext map[string]interface{}
+	// Gam is the minimum loss reduction required to split a leaf, "gamma".
+	Gam float64
+	// Gro is the tree growing policy, either "depthwise" or "lossguide".
+	Gro string
+	// Lea is the learning rate, "learning_rate".
+	Lea float64
+	// Max is the maximum depth of a tree, "max_depth".
+	Max int
+	// Obj is the learning objective, e.g. "reg:logistic".
+	Obj string
+	// Rou is the maximum number of boosting rounds, "num_boost_round".
+	Rou int
+}
+
+// Fill returns a copy of p in which every zero value is replaced with the
+// respective value of def. Ext of both p and def is merged, where the values
+// of p take precedence.
+func (p Params) Fill(def Params) Params {
+	if p.Bas == 0 {
+		p.Bas = def.Bas
+	}
+	if p.Boo == "" {
+		p.Boo = def.Boo
+	}
+	if p.Ear == 0 {
+		p.Ear = def.Ear
+	}
+	if len(p.Eva) == 0 {
+		p.Eva = def.Eva
+	}
+	if p.Gam == 0 {
+		p.Gam = def.Gam
+	}
+	if p.Gro == "" {
+		p.Gro = def.Gro
+	}
+	if p.Lea == 0 {
+		p.Lea = def.Lea
+	}
+	if p.Max == 0 {
+		p.Max = def.Max
+	}
+	if p.Obj == "" {
+		p.Obj = def.Obj
+	}
+	if p.Rou == 0 {
+		p.Rou = def.Rou
+	}
+
+	ext := map[string]interface{}{}
+	for k, v := range def.Ext {
+		ext[k] = v
+	}
+	for k, v := range p.Ext {
+		ext[k] = v
+	}
+	p.Ext = ext
+
+	return p
+}
+
+// Map returns the parameters as passed to the training scripts, including
+// "num_boost_round" and "early_stopping_rounds". Zero values are omitted.
+func (p Params) Map() map[string]interface{} {
+	par := map[string]interface{}{}
+
+	if p.Bas != 0 {
+		par["base_score"] = p.Bas
+	}
+	if p.Boo != "" {
+		par["booster"] = p.Boo
+	}
+	if p.Ear != 0 {
+		par["early_stopping_rounds"] = p.Ear
+	}
+	if len(p.Eva) != 0 {
+		par["eval_metric"] = p.Eva
+	}
+	if p.Gam != 0 {
+		par["gamma"] = p.Gam
+	}
+	if p.Gro != "" {
+		par["grow_policy"] = p.Gro
+	}
+	if p.Lea != 0 {
+		par["learning_rate"] = p.Lea
+	}
+	if p.Max != 0 {
+		par["max_depth"] = p.Max
+	}
+	if p.Obj != "" {
+		par["objective"] = p.Obj
+	}
+	if p.Rou != 0 {
+		par["num_boost_round"] = p.Rou
+	}
+
+	for k, v := range p.Ext {
+		par[k] = v
+	}
+
+	return par
+}
+
+// Validate returns an invalid params error if any of the configured values is
+// out of range or unknown.
+func (p Params) Validate() error {
+	if p.Bas < 0 {
+		return tracer.Maskf(invalidParamsError, "Params.Bas must not be negative")
+	}
+
+	if p.Boo != "" && p.Boo != "gbtree" && p.Boo != "gblinear" && p.Boo != "dart" {
+		return tracer.Maskf(invalidParamsError, "Params.Boo must be gbtree, gblinear or dart, got %q", p.Boo)
+	}
+
+	if p.Ear < 0 {
+		return tracer.Maskf(invalidParamsError, "Params.Ear must not be negative")
+	}
+
+	for _, e := range p.Eva {
+		if e == "" {
+			return tracer.Maskf(invalidParamsError, "Params.Eva must not contain empty eval metrics")
+		}
+	}
+
+	for k := range p.Ext {
+		if k == "" {
+			return tracer.Maskf(invalidParamsError, "Params.Ext must not contain empty keys")
+		}
+	}
+
+	{
+		_, err := json.Marshal(p.Ext)
+		if err != nil {
+			return tracer.Maskf(invalidParamsError, "Params.Ext must be JSON serializable, %s", err)
+		}
+	}
+
+	if p.Gam < 0 {
+		return tracer.Maskf(invalidParamsError, "Params.Gam must not be negative")
+	}
+
+	if p.Gro != "" && p.Gro != "depthwise" && p.Gro != "lossguide" {
+		return tracer.Maskf(invalidParamsError, "Params.Gro must be depthwise or lossguide, got %q", p.Gro)
+	}
+
+	if p.Lea < 0 || p.Lea > 1 {
+		return tracer.Maskf(invalidParamsError, "Params.Lea must be within [0, 1]")
+	}
+
+	if p.Max < 0 {
+		return tracer.Maskf(invalidParamsError, "Params.Max must not be negative")
+	}
+
+	if p.Rou < 0 {
+		return tracer.Maskf(invalidParamsError, "Params.Rou must not be negative")
+	}
+
+	return nil
+}
+
+// Objective returns the learning objective as passed to the training scripts,
+// which is Obj, unless "objective" is overridden in Ext.
+func (p Params) Objective() string {
+	obj, ok := p.Ext["objective"].(string)
+	if ok {
+		return obj
+	}
+
+	return p.Obj
+}
@@ -0,0 +1,282 @@
+package xgboost
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_Params_Fill(t *testing.T) {
+	def := Params{
+		Ear: 10,
+		Eva: []string{"logloss"},
+		Ext: map[string]interface{}{"subsample": 0.8, "tree_method": "hist"},
+		Lea: 0.1,
+		Max: 6,
+		Obj: "reg:logistic",
+		Rou: 100,
+	}
+
+	testCases := []struct {
+		par Params
+		fil Params
+	}{
+		// Case 0, where all defaults apply.
+		{
+			par: Params{},
+			fil: Params{
+				Ear: 10,
+				Eva: []string{"logloss"},
+				Ext: map[string]interface{}{"subsample": 0.8, "tree_method": "hist"},
+				Lea: 0.1,
+				Max: 6,
+				Obj: "reg:logistic",
+				Rou: 100,
+			},
+		},
+		// Case 1, where configured values take precedence, including Ext.
+		{
+			par: Params{
+				Eva: []string{"auc"},
+				Ext: map[string]interface{}{"subsample": 0.5, "gamma": 0},
+				Gro: "lossguide",
+				Max: 8,
+			},
+			fil: Params{
+				Ear: 10,
+				Eva: []string{"auc"},
+				Ext: map[string]interface{}{"gamma": 0, "subsample": 0.5, "tree_method": "hist"},
+				Gro: "lossguide",
+				Lea: 0.1,
+				Max: 8,
+				Obj: "reg:logistic",
+				Rou: 100,
+			},
+		},
+		// Case 2, where zero values cannot be set explicitly without Ext.
+		{
+			par: Params{Ear: 0, Ext: map[string]interface{}{"early_stopping_rounds": 0}},
+			fil: Params{
+				Ear: 10,
+				Eva: []string{"logloss"},
+				Ext: map[string]interface{}{"early_stopping_rounds": 0, "subsample": 0.8, "tree_method": "hist"},
+				Lea: 0.1,
+				Max: 6,
+				Obj: "reg:logistic",
+				Rou: 100,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			fil := tc.par.Fill(def)
+			if !reflect.DeepEqual(fil, tc.fil) {
+				t.Fatalf("expected %#v, got %#v", tc.fil, fil)
+			}
+		})
+	}
+
+	// Filling must not modify Ext of either side.
+	{
+		par := Params{Ext: map[string]interface{}{"gamma": 1}}
+		_ = par.Fill(def)
+
+		if len(par.Ext) != 1 || len(def.Ext) != 2 {
+			t.Fatalf("expected Ext to remain unmodified, got %#v and %#v", par.Ext, def.Ext)
+		}
+	}
+}
+
+func Test_Params_Map(t *testing.T) {
+	testCases := []struct {
+		par Params
+		exp map[string]interface{}
+	}{
+		// Case 0
+		{
+			par: Params{},
+			exp: map[string]interface{}{},
+		},
+		// Case 1
+		{
+			par: Params{
+				Bas: 0.5,
+				Boo: "dart",
+				Ear: 10,
+				Eva: []string{"auc", "logloss"},
+				Gam: 0.1,
+				Gro: "depthwise",
+				Lea: 0.05,
+				Max: 8,
+				Obj: "binary:logistic",
+				Rou: 500,
+			},
+			exp: map[string]interface{}{
+				"base_score":            0.5,
+				"booster":               "dart",
+				"early_stopping_rounds": 10,
+				"eval_metric":           []string{"auc", "logloss"},
+				"gamma":                 0.1,
+				"grow_policy":           "depthwise",
+				"learning_rate":         0.05,
+				"max_depth":             8,
+				"num_boost_round":       500,
+				"objective":             "binary:logistic",
+			},
+		},
+		// Case 2, where Ext overrides the other parameters.
+		{
+			par: Params{
+				Ear: 10,
+				Ext: map[string]interface{}{"early_stopping_rounds": 0, "gamma": 0, "max_depth": 4},
+				Max: 8,
+			},
+			exp: map[string]interface{}{
+				"early_stopping_rounds": 0,
+				"gamma":                 0,
+				"max_depth":             4,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			par := tc.par.Map()
+			if !reflect.DeepEqual(par, tc.exp) {
+				t.Fatalf("expected %#v, got %#v", tc.exp, par)
+			}
+		})
+	}
+}
+
+func Test_Params_Validate(t *testing.T) {
+	testCases := []struct {
+		par Params
+		inv bool
+	}{
+		// Case 0
+		{
+			par: Params{},
+			inv: false,
+		},
+		// Case 1
+		{
+			par: Params{Bas: 0.5, Boo: "gblinear", Ear: 5, Eva: []string{"auc"}, Ext: map[string]interface{}{"gamma": 0}, Gam: 1, Gro: "lossguide", Lea: 1, Max: 4, Rou: 10},
+			inv: false,
+		},
+		// Case 2
+		{
+			par: Params{Bas: -1},
+			inv: true,
+		},
+		// Case 3
+		{
+			par: Params{Boo: "gblinar"},
+			inv: true,
+		},
+		// Case 4
+		{
+			par: Params{Ear: -1},
+			inv: true,
+		},
+		// Case 5
+		{
+			par: Params{Eva: []string{"auc", ""}},
+			inv: true,
+		},
+		// Case 6
+		{
+			par: Params{Ext: map[string]interface{}{"": 1}},
+			inv: true,
+		},
+		// Case 7, where Ext cannot be passed to the training scripts.
+		{
+			par: Params{Ext: map[string]interface{}{"gamma": math.NaN()}},
+			inv: true,
+		},
+		// Case 8
+		{
+			par: Params{Ext: map[string]interface{}{"callback": func() {}}},
+			inv: true,
+		},
+		// Case 9
+		{
+			par: Params{Gam: -0.1},
+			inv: true,
+		},
+		// Case 10
+		{
+			par: Params{Gro: "breadthfirst"},
+			inv: true,
+		},
+		// Case 11
+		{
+			par: Params{Lea: 1.1},
+			inv: true,
+		},
+		// Case 12
+		{
+			par: Params{Lea: -0.1},
+			inv: true,
+		},
+		// Case 13
+		{
+			par: Params{Max: -1},
+			inv: true,
+		},
+		// Case 14
+		{
+			par: Params{Rou: -1},
+			inv: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := tc.par.Validate()
+			if IsInvalidParams(err) != tc.inv {
+				t.Fatalf("expected invalid params error to be %t, got %#v", tc.inv, err)
+			}
+		})
+	}
+}
+
+func Test_Params_Objective(t *testing.T) {
+	testCases := []struct {
+		par Params
+		obj string
+	}{
+		// Case 0
+		{
+			par: Params{},
+			obj: "",
+		},
+		// Case 1
+		{
+			par: Params{Obj: "reg:logistic"},
+			obj: "reg:logistic",
+		},
+		// Case 2, where Ext overrides Obj the way it does for the training
+		// scripts.
+		{
+			par: Params{Ext: map[string]interface{}{"objective": "multi:softprob"}, Obj: "reg:logistic"},
+			obj: "multi:softprob",
+		},
+		// Case 3, where Ext is ignored unless it is a string.
+		{
+			par: Params{Ext: map[string]interface{}{"objective": 1}, Obj: "reg:logistic"},
+			obj: "reg:logistic",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			obj := tc.par.Objective()
+			if obj != tc.obj {
+				t.Fatalf("expected %q, got %q", tc.obj, obj)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package xgboost
+
+// Prediction is the detailed result of an ensemble prediction for a single
+// input.
+type Prediction struct {
+	// Lab is the normalized label. For multiclass objectives Lab is the index
+	// of the most probable class. For all other objectives Lab is the
+	// probability normalized the same way bucket model predictions are
+	// normalized before being fed into the ensemble, by default to either 0,
+	// 0.5 or 1.
+	Lab float32 `json:"lab"`
+	// Mar is the raw ensemble margin, one value per output group, that is one
+	// value per class for multiclass objectives and a single value otherwise.
+	Mar []float32 `json:"mar"`
+	// Mod is the raw prediction of every bucket model per buffer hash and
+	// bucket, before normalization. For multiclass bucket models Mod is the
+	// index of the most probable class. Mod is only set if the loader is
+	// configured to return bucket model outputs.
+	//
+	//     map[string]map[string]float32{
+	//         "foo": { "a": 0.73, "b": 0.12 }, // buffer hash foo
+	//         "bar": { "a": 0.91, "b": 0.08 }, // buffer hash bar
+	//     }
+	//
+	Mod map[string]map[string]float32 `json:"mod,omitempty"`
+	// Pro is the untransformed ensemble prediction, one value per output
+	// group. For multiclass objectives Pro is the probability vector over all
+	// classes. For logistic objectives Pro is the single probability before
+	// normalization.
+	Pro []float32 `json:"pro"`
+}
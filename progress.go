@@ -0,0 +1,69 @@
+package xgboost
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// ProgressStart is emitted once a booster starts training.
+	ProgressStart = "start"
+	// ProgressRound is emitted after every boosting round.
+	ProgressRound = "round"
+	// ProgressStop is emitted if early stopping ended training before the
+	// maximum number of boosting rounds was reached.
+	ProgressStop = "stop"
+	// ProgressDone is emitted once a booster finished training.
+	ProgressDone = "done"
+)
+
+// ProgressMarker is the prefix of the lines training scripts write to stdout
+// in order to report progress, followed by the JSON encoded Progress.
+const ProgressMarker = "XGBOOST-PROGRESS "
+
+// Progress is a structured event emitted by training scripts while training
+// boosters, one booster after another.
+//
+//     {
+//         "booster": "a",
+//         "kind": "round",
+//         "round": 17,
+//         "train": { "error": 0.21, "logloss": 0.48 },
+//         "validation": { "error": 0.25, "logloss": 0.51 }
+//     }
+//
+type Progress struct {
+	// Bes is the best iteration as determined by early stopping, only set for
+	// ProgressStop and ProgressDone.
+	Bes int `json:"best_iteration"`
+	// Boo is the booster being trained, that is the bucket of a bucket model,
+	// or "ensemble" for the ensemble.
+	Boo string `json:"booster"`
+	// Kin is the kind of the event, e.g. ProgressRound.
+	Kin string `json:"kind"`
+	// Rou is the boosting round, starting at 0. For ProgressStop and
+	// ProgressDone Rou is the last boosting round.
+	Rou int `json:"round"`
+	// Tra contains the value of every eval metric on the training set for the
+	// current boosting round, only set for ProgressRound.
+	Tra map[string]float64 `json:"train,omitempty"`
+	// Val contains the value of every eval metric on the validation set, the
+	// same way as Tra.
+	Val map[string]float64 `json:"validation,omitempty"`
+}
+
+// ParseProgress decodes the given line written to stdout by a training
+// script. False is returned if the given line is not a progress event.
+func ParseProgress(lin string) (Progress, bool) {
+	if !strings.HasPrefix(lin, ProgressMarker) {
+		return Progress{}, false
+	}
+
+	var pro Progress
+	err := json.Unmarshal([]byte(strings.TrimPrefix(lin, ProgressMarker)), &pro)
+	if err != nil {
+		return Progress{}, false
+	}
+
+	return pro, true
+}
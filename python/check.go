@@ -0,0 +1,220 @@
+package python
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var (
+	// Serving contains the minimum versions of the Python packages required
+	// by the default loader template.
+	Serving = map[string]string{
+		"numpy":   "1.17",
+		"pandas":  "1.0",
+		"xgboost": "1.6",
+	}
+	// Training contains the minimum versions of the Python packages required
+	// by the default model and ensemble templates.
+	Training = map[string]string{
+		"numpy":   "1.17",
+		"pandas":  "1.0",
+		"sklearn": "0.22",
+		"xgboost": "1.6",
+	}
+)
+
+// chkscr is the Python script reporting the version of every package given as
+// argument, or the reason why it cannot be imported.
+const chkscr = `
+import importlib, json, sys
+res = {}
+for m in sys.argv[1:]:
+  try:
+    res[m] = {"ver": str(getattr(importlib.import_module(m), "__version__", ""))}
+  except Exception as e:
+    res[m] = {"err": f"{type(e).__name__}: {e}"}
+print(json.dumps(res))
+`
+
+// checked remembers the preflight checks that succeeded already, so that
+// every interpreter is only checked once per process for the same
+// requirements.
+var checked sync.Map
+
+// Check verifies that the configured interpreter can import all required
+// Python packages in at least their required versions. The given requirements
+// are extended or overridden by Req. A missing dependency error listing every
+// unmet requirement is returned otherwise. Check is a noop if Ski is set.
+func (c Config) Check(ctx context.Context, req map[string]string) error {
+	{
+		err := c.Validate()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if c.Ski {
+		return nil
+	}
+
+	all := map[string]string{}
+	for k, v := range req {
+		all[k] = v
+	}
+	for k, v := range c.Req {
+		all[k] = v
+	}
+
+	var mod []string
+	for k := range all {
+		mod = append(mod, k)
+	}
+	sort.Strings(mod)
+
+	var key string
+	{
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "%s\x00%s\x00%s\x00%s", c.binary(), c.Dir, c.Env, strings.Join(c.Var, "\x00"))
+		for _, m := range mod {
+			fmt.Fprintf(&buf, "\x00%s=%s", m, all[m])
+		}
+		key = buf.String()
+	}
+
+	if _, ok := checked.Load(key); ok {
+		return nil
+	}
+
+	var out []byte
+	{
+		cmd := exec.CommandContext(ctx, c.binary(), append([]string{"-c", chkscr}, mod...)...)
+		cmd.Dir = c.Dir
+		cmd.Env = c.environ()
+
+		var std bytes.Buffer
+		cmd.Stderr = &std
+
+		var err error
+		out, err = cmd.Output()
+		if err != nil && std.Len() != 0 {
+			return tracer.Maskf(missingDependencyError, "%s: %s: %s", c.binary(), err, strings.TrimSpace(std.String()))
+		} else if err != nil {
+			return tracer.Maskf(missingDependencyError, "%s: %s", c.binary(), err)
+		}
+	}
+
+	var res map[string]struct {
+		Err string `json:"err"`
+		Ver string `json:"ver"`
+	}
+	{
+		err := json.Unmarshal(out, &res)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var fai []string
+	for _, m := range mod {
+		r := res[m]
+
+		if r.Err != "" {
+			fai = append(fai, fmt.Sprintf("%s is missing (%s)", m, r.Err))
+			continue
+		}
+
+		if all[m] != "" && r.Ver != "" && compare(r.Ver, all[m]) < 0 {
+			fai = append(fai, fmt.Sprintf("%s %s is below %s", m, r.Ver, all[m]))
+		}
+	}
+
+	if len(fai) != 0 {
+		return tracer.Maskf(missingDependencyError, "%s: %s", c.binary(), strings.Join(fai, ", "))
+	}
+
+	{
+		checked.Store(key, struct{}{})
+	}
+
+	return nil
+}
+
+// Validate returns an invalid config error if any of the configured values
+// is malformed.
+func (c Config) Validate() error {
+	for _, v := range c.Var {
+		if !strings.Contains(v, "=") || strings.HasPrefix(v, "=") {
+			return tracer.Maskf(invalidConfigError, "Config.Var must only contain key=value pairs, got %q", v)
+		}
+	}
+
+	if c.Lim.Cpu < 0 || c.Lim.Fil < 0 || c.Lim.Mem < 0 {
+		return tracer.Maskf(invalidConfigError, "Config.Lim must not be negative")
+	}
+
+	return nil
+}
+
+// compare compares the numeric release segments of the given versions, e.g.
+// 1.7.6 or 2.0.0rc1, and returns -1, 0 or +1 like strings.Compare.
+func compare(a string, b string) int {
+	x := release(a)
+	y := release(b)
+
+	for i := 0; i < len(x) || i < len(y); i++ {
+		var u, v int
+		if i < len(x) {
+			u = x[i]
+		}
+		if i < len(y) {
+			v = y[i]
+		}
+
+		if u < v {
+			return -1
+		}
+		if u > v {
+			return +1
+		}
+	}
+
+	return 0
+}
+
+// release returns the leading numeric segments of the given version. Any
+// suffix like a pre-release or local version label is ignored.
+func release(ver string) []int {
+	var seg []int
+
+	for _, s := range strings.Split(ver, ".") {
+		var dig string
+		for _, r := range s {
+			if r < '0' || r > '9' {
+				break
+			}
+			dig += string(r)
+		}
+
+		if dig == "" {
+			break
+		}
+
+		n, _ := strconv.Atoi(dig)
+		seg = append(seg, n)
+
+		if len(dig) != len(s) {
+			break
+		}
+	}
+
+	return seg
+}
@@ -0,0 +1,207 @@
+package python
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_Python_Check(t *testing.T) {
+	_, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not available")
+	}
+
+	// The working directory of the interpreter provides a package in version
+	// 1.10, which must satisfy 1.9.
+	dir := t.TempDir()
+	{
+		err := os.MkdirAll(filepath.Join(dir, "xgbtest"), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, "xgbtest", "__init__.py"), []byte("__version__ = \"1.10\"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		req map[string]string
+		mis bool
+	}{
+		// Case 0
+		{
+			req: map[string]string{"xgbtest": ""},
+			mis: false,
+		},
+		// Case 1
+		{
+			req: map[string]string{"xgbtest": "1.9"},
+			mis: false,
+		},
+		// Case 2
+		{
+			req: map[string]string{"xgbtest": "1.10"},
+			mis: false,
+		},
+		// Case 3
+		{
+			req: map[string]string{"xgbtest": "1.11"},
+			mis: true,
+		},
+		// Case 4
+		{
+			req: map[string]string{"xgbtest": "2.0.0rc1"},
+			mis: true,
+		},
+		// Case 5
+		{
+			req: map[string]string{"xgbtest_missing": ""},
+			mis: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := Config{Dir: dir, Req: tc.req}.Check(context.Background(), nil)
+			if IsMissingDependency(err) != tc.mis {
+				t.Fatalf("expected missing dependency error to be %t, got %#v", tc.mis, err)
+			}
+			if !tc.mis && err != nil {
+				t.Fatalf("expected check to succeed, got %#v", err)
+			}
+		})
+	}
+}
+
+func Test_Python_Compare(t *testing.T) {
+	testCases := []struct {
+		a string
+		b string
+		c int
+	}{
+		// Case 0, where pre-releases are compared by their release segments.
+		{
+			a: "2.0.0rc1",
+			b: "2.0",
+			c: 0,
+		},
+		// Case 1, where segments are compared numerically.
+		{
+			a: "1.10",
+			b: "1.9",
+			c: +1,
+		},
+		// Case 2
+		{
+			a: "1.9",
+			b: "1.10",
+			c: -1,
+		},
+		// Case 3, where missing segments are zero.
+		{
+			a: "1.7",
+			b: "1.7.0",
+			c: 0,
+		},
+		// Case 4
+		{
+			a: "1.7.6",
+			b: "1.7",
+			c: +1,
+		},
+		// Case 5
+		{
+			a: "0.22.2.post1",
+			b: "0.22",
+			c: +1,
+		},
+		// Case 6, where local version labels are ignored.
+		{
+			a: "2.1.0+cu118",
+			b: "2.1",
+			c: 0,
+		},
+		// Case 7
+		{
+			a: "1.26.4",
+			b: "2.0",
+			c: -1,
+		},
+		// Case 8
+		{
+			a: "",
+			b: "1.0",
+			c: -1,
+		},
+		// Case 9
+		{
+			a: "",
+			b: "",
+			c: 0,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := compare(tc.a, tc.b)
+			if c != tc.c {
+				t.Fatalf("expected %d, got %d", tc.c, c)
+			}
+		})
+	}
+}
+
+func Test_Python_Release(t *testing.T) {
+	testCases := []struct {
+		ver string
+		seg []int
+	}{
+		// Case 0
+		{
+			ver: "2.0.0rc1",
+			seg: []int{2, 0, 0},
+		},
+		// Case 1
+		{
+			ver: "1.10",
+			seg: []int{1, 10},
+		},
+		// Case 2, where segments after a suffix are ignored.
+		{
+			ver: "1.2rc1.3",
+			seg: []int{1, 2},
+		},
+		// Case 3
+		{
+			ver: "2.0.0.dev0",
+			seg: []int{2, 0, 0},
+		},
+		// Case 4
+		{
+			ver: "v1.0",
+			seg: nil,
+		},
+		// Case 5
+		{
+			ver: "",
+			seg: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			seg := release(tc.ver)
+			if !reflect.DeepEqual(seg, tc.seg) {
+				t.Fatalf("expected %v, got %v", tc.seg, seg)
+			}
+		})
+	}
+}
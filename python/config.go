@@ -0,0 +1,143 @@
+package python
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Config describes how Python child processes are being executed. The zero
+// value executes "python3" as found in PATH, within the working directory and
+// the environment of the current process, without any resource limits.
+//
+//     cfg := python.Config{
+//         Env: "/Users/xh3b4sd/.venv/xgboost/",
+//         Var: []string{"OMP_NUM_THREADS=4"},
+//     }
+//
+type Config struct {
+	// Bin is the optional path of the Python interpreter. Defaults to
+	// "<Env>/bin/python3" if Env is set, and to "python3" otherwise.
+	Bin string
+	// Dir is the optional working directory of the child process. Defaults to
+	// the working directory of the current process.
+	Dir string
+	// Env is the optional prefix of a virtualenv or conda environment, which is
+	// activated for the child process.
+	Env string
+	// Lim contains the optional resource limits of the child process.
+	Lim Limits
+	// Req contains optional minimum versions of Python packages, which extend
+	// or override the requirements of the respective runner checked before
+	// spawning any child process. An empty version only requires the package
+	// to be importable.
+	//
+	//     map[string]string{
+	//         "xgboost": "2.0",
+	//     }
+	//
+	Req map[string]string
+	// Ski disables the preflight check of the required Python packages.
+	Ski bool
+	// Var contains optional extra environment variables of the child process
+	// in the form "key=value", e.g. "OMP_NUM_THREADS=4". The child process
+	// inherits the environment of the current process in any case.
+	Var []string
+}
+
+// Limits describes resource limits applied to a child process via the
+// resource module of Python, before the actual script gets executed. Zero
+// values do not limit anything.
+type Limits struct {
+	// Cpu is the maximum CPU time of the child process in seconds.
+	Cpu int
+	// Fil is the maximum number of open file descriptors of the child process.
+	Fil int
+	// Mem is the maximum size of the virtual memory of the child process in
+	// bytes.
+	Mem int64
+}
+
+// Command returns the command executing the given Python script.
+func (c Config) Command(scr string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if c.Lim.empty() {
+		cmd = exec.Command(c.binary(), scr)
+	} else {
+		cmd = exec.Command(c.binary(), "-c", c.Lim.prelude(), scr)
+	}
+
+	{
+		cmd.Dir = c.Dir
+		cmd.Env = c.environ()
+	}
+
+	return cmd
+}
+
+func (c Config) binary() string {
+	if c.Bin != "" {
+		return c.Bin
+	}
+
+	if c.Env != "" {
+		return filepath.Join(c.Env, "bin", "python3")
+	}
+
+	return "python3"
+}
+
+// environ returns the environment of the child process, or nil if the child
+// process simply inherits the environment of the current process.
+func (c Config) environ() []string {
+	if c.Env == "" && len(c.Var) == 0 {
+		return nil
+	}
+
+	env := os.Environ()
+
+	if c.Env != "" {
+		env = append(env,
+			"CONDA_PREFIX="+c.Env,
+			"PATH="+filepath.Join(c.Env, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+			"VIRTUAL_ENV="+c.Env,
+		)
+	}
+
+	// Later entries take precedence over earlier ones with the same key, so
+	// that the extra variables override the inherited ones.
+	env = append(env, c.Var...)
+
+	return env
+}
+
+func (l Limits) empty() bool {
+	return l.Cpu == 0 && l.Fil == 0 && l.Mem == 0
+}
+
+// prelude returns the Python code applying the resource limits and executing
+// the script given as first argument afterwards.
+func (l Limits) prelude() string {
+	var lin []string
+
+	lin = append(lin, "import resource, runpy, sys")
+
+	if l.Cpu != 0 {
+		lin = append(lin, fmt.Sprintf("resource.setrlimit(resource.RLIMIT_CPU, (%d, %d))", l.Cpu, l.Cpu))
+	}
+
+	if l.Fil != 0 {
+		lin = append(lin, fmt.Sprintf("resource.setrlimit(resource.RLIMIT_NOFILE, (%d, %d))", l.Fil, l.Fil))
+	}
+
+	if l.Mem != 0 {
+		lin = append(lin, fmt.Sprintf("resource.setrlimit(resource.RLIMIT_AS, (%d, %d))", l.Mem, l.Mem))
+	}
+
+	lin = append(lin, "sys.argv = sys.argv[1:]")
+	lin = append(lin, "runpy.run_path(sys.argv[0], run_name=\"__main__\")")
+
+	return strings.Join(lin, "\n")
+}
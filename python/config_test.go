@@ -0,0 +1,253 @@
+package python
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Python_Config_Command(t *testing.T) {
+	testCases := []struct {
+		cfg Config
+		arg []string
+	}{
+		// Case 0
+		{
+			cfg: Config{},
+			arg: []string{"python3", "s.py"},
+		},
+		// Case 1
+		{
+			cfg: Config{Env: "/venv"},
+			arg: []string{filepath.Join("/venv", "bin", "python3"), "s.py"},
+		},
+		// Case 2, where Bin takes precedence over Env.
+		{
+			cfg: Config{Bin: "/usr/bin/python3.11", Env: "/venv"},
+			arg: []string{"/usr/bin/python3.11", "s.py"},
+		},
+		// Case 3, where limits are applied by the prelude.
+		{
+			cfg: Config{Lim: Limits{Fil: 64}},
+			arg: []string{"python3", "-c", Limits{Fil: 64}.prelude(), "s.py"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cmd := tc.cfg.Command("s.py")
+			if !reflect.DeepEqual(cmd.Args, tc.arg) {
+				t.Fatalf("expected %q, got %q", tc.arg, cmd.Args)
+			}
+		})
+	}
+}
+
+func Test_Python_Config_Environ(t *testing.T) {
+	t.Setenv("XGBTEST", "inherited")
+
+	testCases := []struct {
+		cfg Config
+		key string
+		val string
+	}{
+		// Case 0, where the environment is inherited.
+		{
+			cfg: Config{},
+			key: "XGBTEST",
+			val: "inherited",
+		},
+		// Case 1, where extra variables override inherited ones.
+		{
+			cfg: Config{Var: []string{"XGBTEST=extra"}},
+			key: "XGBTEST",
+			val: "extra",
+		},
+		// Case 2, where later extra variables override earlier ones.
+		{
+			cfg: Config{Var: []string{"XGBTEST=first", "XGBTEST=second"}},
+			key: "XGBTEST",
+			val: "second",
+		},
+		// Case 3
+		{
+			cfg: Config{Env: "/venv"},
+			key: "VIRTUAL_ENV",
+			val: "/venv",
+		},
+		// Case 4
+		{
+			cfg: Config{Env: "/venv"},
+			key: "CONDA_PREFIX",
+			val: "/venv",
+		},
+		// Case 5, where the environment is activated by prepending to PATH.
+		{
+			cfg: Config{Env: "/venv"},
+			key: "PATH",
+			val: filepath.Join("/venv", "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+		},
+		// Case 6, where extra variables override the activated environment.
+		{
+			cfg: Config{Env: "/venv", Var: []string{"VIRTUAL_ENV=/other"}},
+			key: "VIRTUAL_ENV",
+			val: "/other",
+		},
+		// Case 7
+		{
+			cfg: Config{Env: "/venv"},
+			key: "XGBTEST",
+			val: "inherited",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			env := tc.cfg.environ()
+			if env == nil {
+				env = os.Environ()
+			}
+
+			// The last entry of a key takes precedence, the way exec.Cmd
+			// deduplicates the environment.
+			var val string
+			for _, e := range env {
+				if strings.HasPrefix(e, tc.key+"=") {
+					val = strings.TrimPrefix(e, tc.key+"=")
+				}
+			}
+
+			if val != tc.val {
+				t.Fatalf("expected %s=%s, got %s=%s", tc.key, tc.val, tc.key, val)
+			}
+		})
+	}
+
+	if (Config{}).environ() != nil {
+		t.Fatalf("expected nil environment to be inherited")
+	}
+}
+
+func Test_Python_Config_Validate(t *testing.T) {
+	testCases := []struct {
+		cfg Config
+		inv bool
+	}{
+		// Case 0
+		{
+			cfg: Config{},
+			inv: false,
+		},
+		// Case 1
+		{
+			cfg: Config{Var: []string{"OMP_NUM_THREADS=4", "EMPTY="}},
+			inv: false,
+		},
+		// Case 2
+		{
+			cfg: Config{Var: []string{"OMP_NUM_THREADS"}},
+			inv: true,
+		},
+		// Case 3
+		{
+			cfg: Config{Var: []string{"=4"}},
+			inv: true,
+		},
+		// Case 4
+		{
+			cfg: Config{Lim: Limits{Cpu: 1, Fil: 64, Mem: 1 << 30}},
+			inv: false,
+		},
+		// Case 5
+		{
+			cfg: Config{Lim: Limits{Mem: -1}},
+			inv: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if IsInvalidConfig(err) != tc.inv {
+				t.Fatalf("expected invalid config error to be %t, got %#v", tc.inv, err)
+			}
+		})
+	}
+}
+
+func Test_Python_Limits_Prelude(t *testing.T) {
+	testCases := []struct {
+		lim Limits
+		pre string
+	}{
+		// Case 0
+		{
+			lim: Limits{Cpu: 2},
+			pre: `import resource, runpy, sys
+resource.setrlimit(resource.RLIMIT_CPU, (2, 2))
+sys.argv = sys.argv[1:]
+runpy.run_path(sys.argv[0], run_name="__main__")`,
+		},
+		// Case 1
+		{
+			lim: Limits{Cpu: 2, Fil: 64, Mem: 1073741824},
+			pre: `import resource, runpy, sys
+resource.setrlimit(resource.RLIMIT_CPU, (2, 2))
+resource.setrlimit(resource.RLIMIT_NOFILE, (64, 64))
+resource.setrlimit(resource.RLIMIT_AS, (1073741824, 1073741824))
+sys.argv = sys.argv[1:]
+runpy.run_path(sys.argv[0], run_name="__main__")`,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			pre := tc.lim.prelude()
+			if pre != tc.pre {
+				t.Fatalf("expected\n%s\ngot\n%s", tc.pre, pre)
+			}
+		})
+	}
+}
+
+// Test_Python_Limits_Prelude_Execute ensures that the prelude applies the
+// limits and executes the script as __main__, with the script as the first
+// argument.
+func Test_Python_Limits_Prelude_Execute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("resource limits are not supported on windows")
+	}
+
+	{
+		_, err := exec.LookPath("python3")
+		if err != nil {
+			t.Skip("python3 is not available")
+		}
+	}
+
+	scr := filepath.Join(t.TempDir(), "s.py")
+	{
+		err := ioutil.WriteFile(scr, []byte(`import os, resource, sys
+if __name__ == "__main__":
+  print(os.path.basename(sys.argv[0]), resource.getrlimit(resource.RLIMIT_NOFILE)[0])
+`), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := Config{Lim: Limits{Fil: 64}}.Command(scr).Output()
+	if err != nil {
+		t.Fatalf("expected script to succeed, got %#v", err)
+	}
+
+	if strings.TrimSpace(string(out)) != "s.py 64" {
+		t.Fatalf("expected s.py 64, got %q", out)
+	}
+}
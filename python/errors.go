@@ -0,0 +1,23 @@
+package python
+
+import (
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var invalidConfigError = &tracer.Error{
+	Kind: "invalidConfigError",
+}
+
+func IsInvalidConfig(err error) bool {
+	return errors.Is(err, invalidConfigError)
+}
+
+var missingDependencyError = &tracer.Error{
+	Kind: "missingDependencyError",
+}
+
+func IsMissingDependency(err error) bool {
+	return errors.Is(err, missingDependencyError)
+}
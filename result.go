@@ -0,0 +1,58 @@
+package xgboost
+
+// TrainResult is the result of a training run, as written by the training
+// scripts into res.json next to the trained models.
+//
+//     {
+//         "ensemble": {
+//             "best_iteration": 112,
+//             "train": { "error": [ ... ], "logloss": [ ... ] },
+//             "validation": { "error": [ ... ], "logloss": [ ... ] }
+//         },
+//         "log_err": 0.0413,
+//         "models": { "a": { ... }, "b": { ... } },
+//         "saved": true,
+//         "timings": { "data": 1.2, "ensemble": 3.4, "models": 56.7, "total": 61.3 }
+//     }
+//
+type TrainResult struct {
+	// Ens is the result of training the ensemble.
+	Ens BoosterResult `json:"ensemble"`
+	// Log is the mean squared logarithmic error of the ensemble predicting the
+	// test set.
+	Log float64 `json:"log_err"`
+	// Mod is the result of training every bucket model per bucket. Mod is only
+	// set when training models, since ensembles are trained on top of already
+	// trained bucket models.
+	Mod map[string]BoosterResult `json:"models,omitempty"`
+	// Sav is whether the trained models got saved. Bucket models are only
+	// saved if Log is below the maximum logarithmic error configured for
+	// training, unless existing models got updated. Ensembles are always saved.
+	Sav bool `json:"saved"`
+	// Tim contains the durations of the individual training stages.
+	Tim TrainTimings `json:"timings"`
+}
+
+// BoosterResult is the result of training a single booster.
+type BoosterResult struct {
+	// Bes is the best iteration as determined by early stopping.
+	Bes int `json:"best_iteration"`
+	// Tra is the eval history of the training set, that is the value of every
+	// eval metric per boosting round, e.g. "logloss".
+	Tra map[string][]float64 `json:"train"`
+	// Val is the eval history of the validation set, the same way as Tra.
+	Val map[string][]float64 `json:"validation"`
+}
+
+// TrainTimings contains the durations of the individual training stages in
+// seconds.
+type TrainTimings struct {
+	// Dat is the time spent loading data sets.
+	Dat float64 `json:"data"`
+	// Ens is the time spent training the ensemble.
+	Ens float64 `json:"ensemble"`
+	// Mod is the time spent training all bucket models.
+	Mod float64 `json:"models"`
+	// Tot is the total time spent within the training script.
+	Tot float64 `json:"total"`
+}
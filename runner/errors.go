@@ -0,0 +1,15 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var inUseError = &tracer.Error{
+	Kind: "inUseError",
+}
+
+func IsInUse(err error) bool {
+	return errors.Is(err, inUseError)
+}
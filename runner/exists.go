@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"os"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// Exists returns whether the given file exists.
+func Exists(file string) (bool, error) {
+	_, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, tracer.Mask(err)
+	}
+
+	return true, nil
+}
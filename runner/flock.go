@@ -0,0 +1,96 @@
+//go:build !windows
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// Lock acquires an exclusive advisory lock on the given file, which is
+// created if necessary. The PID of the current process is written into the
+// lock file, so that other processes failing to acquire the lock can name
+// its holder. If wai is set, Lock waits for the lock to be released until the
+// given context gets canceled, in which case the error of the context is
+// returned. Otherwise an in use error is returned right away. The lock is
+// released by closing the returned file, which happens implicitly once the
+// current process exits.
+func Lock(ctx context.Context, pat string, wai bool) (*os.File, error) {
+	fil, err := os.OpenFile(pat, os.O_RDWR|os.O_CREATE, 0664)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	for {
+		err = syscall.Flock(int(fil.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			fil.Close()
+			return nil, tracer.Mask(err)
+		}
+
+		if !wai {
+			fil.Close()
+			return nil, tracer.Maskf(inUseError, "%s is locked by PID %s", pat, holder(pat))
+		}
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			fil.Close()
+			return nil, tracer.Mask(ctx.Err())
+		}
+	}
+
+	{
+		err = fil.Truncate(0)
+		if err != nil {
+			fil.Close()
+			return nil, tracer.Mask(err)
+		}
+
+		_, err = fil.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+		if err != nil {
+			fil.Close()
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	return fil, nil
+}
+
+// Unlock releases the lock acquired via Lock, if any.
+func Unlock(fil *os.File) error {
+	if fil == nil {
+		return nil
+	}
+
+	err := fil.Close()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// holder returns the PID written into the given lock file, which may not be
+// written yet if the holder just acquired the lock.
+func holder(pat string) string {
+	byt, err := ioutil.ReadFile(pat)
+	if err != nil || len(strings.TrimSpace(string(byt))) == 0 {
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(byt))
+}
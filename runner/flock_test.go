@@ -0,0 +1,100 @@
+//go:build !windows
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Runner_Lock_Contention(t *testing.T) {
+	pat := filepath.Join(t.TempDir(), "model.lock")
+
+	fir, err := Lock(context.Background(), pat, false)
+	if err != nil {
+		t.Fatalf("expected first lock to succeed, got %#v", err)
+	}
+
+	{
+		_, err = Lock(context.Background(), pat, false)
+		if !IsInUse(err) {
+			t.Fatalf("expected in use error, got %#v", err)
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("PID %d", os.Getpid())) {
+			t.Fatalf("expected error to name the lock holder, got %q", err.Error())
+		}
+	}
+
+	{
+		err = Unlock(fir)
+		if err != nil {
+			t.Fatalf("expected unlock to succeed, got %#v", err)
+		}
+	}
+
+	sec, err := Lock(context.Background(), pat, false)
+	if err != nil {
+		t.Fatalf("expected lock after unlock to succeed, got %#v", err)
+	}
+
+	{
+		err = Unlock(sec)
+		if err != nil {
+			t.Fatalf("expected unlock to succeed, got %#v", err)
+		}
+	}
+}
+
+func Test_Runner_Lock_Context(t *testing.T) {
+	pat := filepath.Join(t.TempDir(), "model.lock")
+
+	fir, err := Lock(context.Background(), pat, false)
+	if err != nil {
+		t.Fatalf("expected first lock to succeed, got %#v", err)
+	}
+
+	defer Unlock(fir)
+
+	ctx, can := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer can()
+
+	_, err = Lock(ctx, pat, true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %#v", err)
+	}
+}
+
+func Test_Runner_Lock_Wait(t *testing.T) {
+	pat := filepath.Join(t.TempDir(), "model.lock")
+
+	fir, err := Lock(context.Background(), pat, false)
+	if err != nil {
+		t.Fatalf("expected first lock to succeed, got %#v", err)
+	}
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = Unlock(fir)
+	}()
+
+	ctx, can := context.WithTimeout(context.Background(), 5*time.Second)
+	defer can()
+
+	sec, err := Lock(ctx, pat, true)
+	if err != nil {
+		t.Fatalf("expected waiting lock to succeed once released, got %#v", err)
+	}
+
+	{
+		err = Unlock(sec)
+		if err != nil {
+			t.Fatalf("expected unlock to succeed, got %#v", err)
+		}
+	}
+}
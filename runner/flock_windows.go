@@ -0,0 +1,19 @@
+//go:build windows
+
+package runner
+
+import (
+	"context"
+	"os"
+)
+
+// Lock is a noop on Windows, since advisory file locks as used on Unix
+// systems are not available.
+func Lock(ctx context.Context, pat string, wai bool) (*os.File, error) {
+	return nil, nil
+}
+
+// Unlock is a noop on Windows.
+func Unlock(fil *os.File) error {
+	return nil
+}
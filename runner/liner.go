@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// liner splits the output of a child process into lines, calling the given
+// hook with every line. Lines not consumed by the hook are forwarded to the
+// given writer, if any.
+type liner struct {
+	buf []byte
+	fun func(string) bool
+	wri io.Writer
+}
+
+func (l *liner) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := l.line(l.buf[:i+1])
+		if err != nil {
+			return 0, err
+		}
+
+		l.buf = l.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// flush processes the last line in case it is not terminated by a newline.
+func (l *liner) flush() error {
+	if len(l.buf) == 0 {
+		return nil
+	}
+
+	err := l.line(l.buf)
+	if err != nil {
+		return err
+	}
+
+	l.buf = nil
+
+	return nil
+}
+
+func (l *liner) line(lin []byte) error {
+	if l.fun(strings.TrimRight(string(lin), "\r\n")) || l.wri == nil {
+		return nil
+	}
+
+	_, err := l.wri.Write(lin)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/xh3b4sd/tracer"
+	"github.com/xh3b4sd/xgboost/python"
+)
+
+// Runner executes rendered Python scripts in child processes. It takes care
+// of everything the model, the ensemble and the loader have in common, that
+// is persisting the script in a temp file, remembering the temp file in a
+// marker file, starting the interpreter in its own process group, wiring
+// stdout and stderr, and reaping whatever the child process left behind.
+//
+//     run := runner.Runner{
+//         Mar: "/Users/xh3b4sd/dat/loader.pat",
+//         Nam: "loader",
+//     }
+//
+type Runner struct {
+	// Deb forwards stdout and stderr of the child process to stdout and
+	// stderr of the current process.
+	Deb bool
+	// Hoo contains optional hooks for customizing the execution of the child
+	// process.
+	Hoo Hooks
+	// Mar is the required path of the marker file the path of the temp file is
+	// written into, so that temp files left behind by a crashed process can be
+	// cleaned up later on, e.g. "/Users/xh3b4sd/dat/loader.pat".
+	Mar string
+	// Nam is the required name of the runner, which is part of the temp file
+	// name, e.g. "loader" for "xgboost-loader-template-*".
+	Nam string
+	// Pde enables the parent death signal on Linux, which kills the child
+	// process once the current process exits.
+	Pde bool
+	// Pyt optionally configures the Python interpreter, its environment and
+	// the resource limits of the child process.
+	Pyt python.Config
+	// Set is optionally called with the command right before it gets started,
+	// after stdout and stderr got wired, e.g. in order to connect pipes.
+	Set func(*exec.Cmd) error
+}
+
+// Hooks customize the execution of child processes.
+type Hooks struct {
+	// Cla optionally classifies the exit of the child process, given its exit
+	// state and the error returned by waiting for it. The returned error
+	// replaces the original one, e.g. in order to treat certain exit codes as
+	// success.
+	Cla func(*os.ProcessState, error) error
+	// Err optionally captures stderr of the child process, regardless of Deb.
+	Err io.Writer
+	// Kee retains the temp files of rendered scripts after the child process
+	// exited, e.g. in order to inspect or execute them manually.
+	Kee bool
+	// Lin is optionally called with every line the child process writes to
+	// stdout, without the trailing newline. Lines Lin returns true for are
+	// consumed, and are neither forwarded to stdout nor to Out.
+	Lin func(string) bool
+	// Out optionally captures stdout of the child process, regardless of Deb.
+	Out io.Writer
+}
+
+// Child is a started child process.
+type Child struct {
+	Cmd *exec.Cmd
+	// Fil is the closed temp file containing the rendered script.
+	Fil *os.File
+
+	don chan struct{}
+	err error
+	// exp is set to 1 once the exit of the child process is expected.
+	exp int32
+	kee bool
+	lin *liner
+}
+
+// Literal returns the given value as Python string literal containing JSON,
+// to be decoded via json.loads within rendered scripts. Marshalling cannot
+// fail for validated configurations.
+func Literal(val interface{}) string {
+	byt, _ := json.Marshal(val)
+	return strconv.Quote(string(byt))
+}
+
+// Render renders the given Python script template using the given values.
+func Render(nam string, tem string, val interface{}) ([]byte, error) {
+	t, err := template.New(nam).Parse(tem)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, val)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Cleanup removes the temp file remembered in the marker file, unless Hoo.Kee
+// is set, and the marker file itself. Nothing happens if there is no marker
+// file.
+func (r Runner) Cleanup() error {
+	var err error
+
+	var exi bool
+	{
+		exi, err = Exists(r.Mar)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if !exi {
+		return nil
+	}
+
+	if !r.Hoo.Kee {
+		var byt []byte
+		{
+			byt, err = ioutil.ReadFile(r.Mar)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		err = remove(strings.TrimSpace(string(byt)))
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	{
+		err = os.Remove(r.Mar)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// Start persists the given script in a temp file, remembers the temp file in
+// the marker file and starts executing the script in a child process. Once the
+// child process exited, anything it spawned itself and left behind is killed
+// together with the rest of its process group.
+func (r Runner) Start(scr []byte) (*Child, error) {
+	var err error
+
+	var fil *os.File
+	{
+		fil, err = os.CreateTemp("", "xgboost-"+r.Nam+"-template-*")
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		_, err = fil.Write(scr)
+		if err != nil {
+			fil.Close()
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		err = fil.Close()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		err = ioutil.WriteFile(r.Mar, []byte(fil.Name()), 0664)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var cmd *exec.Cmd
+	{
+		cmd = r.Pyt.Command(fil.Name())
+		sysproc(cmd, r.Pde)
+	}
+
+	var lin *liner
+	{
+		cmd.Stdout = writer(r.Deb, os.Stdout, r.Hoo.Out)
+		cmd.Stderr = writer(r.Deb, os.Stderr, r.Hoo.Err)
+	}
+
+	if r.Hoo.Lin != nil {
+		lin = &liner{fun: r.Hoo.Lin, wri: cmd.Stdout}
+		cmd.Stdout = lin
+	}
+
+	if r.Set != nil {
+		err = r.Set(cmd)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	{
+		err = cmd.Start()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	c := &Child{
+		Cmd: cmd,
+		Fil: fil,
+
+		don: make(chan struct{}),
+		kee: r.Hoo.Kee,
+		lin: lin,
+	}
+
+	go c.wait(r.Hoo.Cla)
+
+	return c, nil
+}
+
+// Done returns a channel that is closed once the child process exited and its
+// process group got killed.
+func (c *Child) Done() <-chan struct{} {
+	return c.don
+}
+
+// Err returns the error the child process exited with, if any. Err must only
+// be called after Done got closed.
+func (c *Child) Err() error {
+	return c.err
+}
+
+// Expect marks the exit of the child process as expected, e.g. before asking
+// the child process to stop by other means than Kill or Term.
+func (c *Child) Expect() {
+	atomic.StoreInt32(&c.exp, 1)
+}
+
+// Expected returns whether the exit of the child process is expected, that
+// is whether Expect, Kill or Term got called.
+func (c *Child) Expected() bool {
+	return atomic.LoadInt32(&c.exp) == 1
+}
+
+// Kill kills the process group of the child process, whose exit is expected
+// afterwards.
+func (c *Child) Kill() error {
+	c.Expect()
+
+	err := Kill(c.Cmd.Process.Pid)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// Remove removes the temp file of the child process, unless Hoo.Kee is set.
+func (c *Child) Remove() error {
+	if c.kee {
+		return nil
+	}
+
+	err := remove(c.Fil.Name())
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// Term asks the process group of the child process to terminate, whose exit is
+// expected afterwards.
+func (c *Child) Term() error {
+	c.Expect()
+
+	err := Term(c.Cmd.Process.Pid)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *Child) wait(cla func(*os.ProcessState, error) error) {
+	err := c.Cmd.Wait()
+
+	// Waiting for the child process includes waiting for its output to be
+	// copied, so that the last line can be flushed safely.
+	if c.lin != nil {
+		e := c.lin.flush()
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if cla != nil {
+		err = cla(c.Cmd.ProcessState, err)
+	}
+
+	{
+		e := Kill(c.Cmd.Process.Pid)
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+
+	c.err = err
+	close(c.don)
+}
+
+// remove removes the given file, if it exists.
+func remove(pat string) error {
+	exi, err := Exists(pat)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	if exi {
+		err := os.Remove(pat)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// writer returns the writer stdout or stderr of a child process is wired to,
+// which is nil if nothing is interested in the output.
+func writer(deb bool, std io.Writer, cap io.Writer) io.Writer {
+	var wri []io.Writer
+
+	if deb {
+		wri = append(wri, std)
+	}
+
+	if cap != nil {
+		wri = append(wri, cap)
+	}
+
+	if len(wri) == 0 {
+		return nil
+	}
+
+	if len(wri) == 1 {
+		return wri[0]
+	}
+
+	return io.MultiWriter(wri...)
+}
@@ -0,0 +1,167 @@
+//go:build linux
+
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xh3b4sd/xgboost/python"
+)
+
+// Test_Runner_Start_Group ensures that killing a child process kills its
+// whole process group, including the grandchild processes it spawned.
+func Test_Runner_Start_Group(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+
+	gra := make(chan int, 1)
+
+	run := Runner{
+		Hoo: Hooks{
+			Lin: func(lin string) bool {
+				pid, err := strconv.Atoi(strings.TrimPrefix(lin, "PID "))
+				if err == nil {
+					gra <- pid
+				}
+				return true
+			},
+		},
+		Mar: filepath.Join(t.TempDir(), "test.pat"),
+		Nam: "test",
+		Pyt: python.Config{Ski: true},
+	}
+
+	scr := "import subprocess, sys, time\n" +
+		"p = subprocess.Popen(['sleep', '60'])\n" +
+		"print('PID %d' % p.pid, flush=True)\n" +
+		"time.sleep(60)\n"
+
+	chi, err := run.Start([]byte(scr))
+	if err != nil {
+		t.Fatalf("expected start to succeed, got %#v", err)
+	}
+
+	var pid int
+	select {
+	case pid = <-gra:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected grandchild PID within 10 seconds")
+	}
+
+	{
+		err = chi.Kill()
+		if err != nil {
+			t.Fatalf("expected kill to succeed, got %#v", err)
+		}
+	}
+
+	select {
+	case <-chi.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected child process to exit within 10 seconds")
+	}
+
+	if chi.Err() == nil {
+		t.Fatalf("expected killed child process to return an error")
+	}
+
+	for i := 0; alive(pid); i++ {
+		if i == 100 {
+			t.Fatalf("expected grandchild process %d to be killed", pid)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	{
+		err = run.Cleanup()
+		if err != nil {
+			t.Fatalf("expected cleanup to succeed, got %#v", err)
+		}
+	}
+
+	for _, f := range []string{run.Mar, chi.Fil.Name()} {
+		exi, err := Exists(f)
+		if err != nil {
+			t.Fatalf("expected stat to succeed, got %#v", err)
+		}
+		if exi {
+			t.Fatalf("expected %s to be removed", f)
+		}
+	}
+}
+
+// Test_Runner_Start_Hooks ensures that the exit of the child process can be
+// classified and that its output can be captured.
+func Test_Runner_Start_Hooks(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+
+	var out strings.Builder
+
+	exp := errors.New("exit code 3")
+
+	run := Runner{
+		Hoo: Hooks{
+			Cla: func(sta *os.ProcessState, err error) error {
+				if sta != nil && sta.ExitCode() == 3 {
+					return exp
+				}
+				return err
+			},
+			Out: &out,
+		},
+		Mar: filepath.Join(t.TempDir(), "test.pat"),
+		Nam: "test",
+		Pyt: python.Config{Ski: true},
+	}
+
+	chi, err := run.Start([]byte("print('hello')\nraise SystemExit(3)\n"))
+	if err != nil {
+		t.Fatalf("expected start to succeed, got %#v", err)
+	}
+
+	select {
+	case <-chi.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected child process to exit within 10 seconds")
+	}
+
+	if chi.Err() != exp {
+		t.Fatalf("expected classified error, got %#v", chi.Err())
+	}
+
+	if out.String() != "hello\n" {
+		t.Fatalf("expected captured stdout %q, got %q", "hello\n", out.String())
+	}
+
+	{
+		err = run.Cleanup()
+		if err != nil {
+			t.Fatalf("expected cleanup to succeed, got %#v", err)
+		}
+	}
+}
+
+// alive returns whether the given process exists and is not a zombie, which
+// may linger until reaped by whatever process adopted it.
+func alive(pid int) bool {
+	byt, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+
+	fie := strings.Fields(string(byt[strings.LastIndexByte(string(byt), ')')+1:]))
+
+	return len(fie) != 0 && fie[0] != "Z"
+}
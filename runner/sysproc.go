@@ -0,0 +1,45 @@
+//go:build !windows
+
+package runner
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// sysproc configures the given command to start its process within its own
+// process group, so that the process can be killed together with anything
+// it spawned itself. If pde is set, the process is killed once the current
+// process exits, where supported.
+func sysproc(cmd *exec.Cmd, pde bool) {
+	att := &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	pdeathsig(att, pde)
+
+	cmd.SysProcAttr = att
+}
+
+// Kill kills the process group of the given process ID, which is expected
+// to be the leader of its own process group as configured via sysproc.
+func Kill(pid int) error {
+	err := syscall.Kill(-pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+
+	return err
+}
+
+// Term asks the process group of the given process ID to terminate, by
+// sending SIGTERM.
+func Term(pid int) error {
+	err := syscall.Kill(-pid, syscall.SIGTERM)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package runner
+
+import "syscall"
+
+// pdeathsig configures the process to be killed once the current process
+// exits, if pde is set.
+func pdeathsig(att *syscall.SysProcAttr, pde bool) {
+	if pde {
+		att.Pdeathsig = syscall.SIGKILL
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package runner
+
+import "syscall"
+
+// pdeathsig is a noop on platforms other than Linux, since there is no parent
+// death signal.
+func pdeathsig(att *syscall.SysProcAttr, pde bool) {}
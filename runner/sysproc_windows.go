@@ -0,0 +1,35 @@
+//go:build windows
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// sysproc is a noop on Windows, since neither process groups nor parent death
+// signals as used on Unix systems are available.
+func sysproc(cmd *exec.Cmd, pde bool) {}
+
+// Kill kills the process of the given process ID. Any process spawned by
+// the given process is not affected on Windows.
+func Kill(pid int) error {
+	pro, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	err = pro.Kill()
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+
+	return err
+}
+
+// Term kills the process of the given process ID, since there is no way to
+// ask a process to terminate on Windows.
+func Term(pid int) error {
+	return Kill(pid)
+}
@@ -1,17 +1,39 @@
 package xgboost
 
+import "context"
+
 type Ensemble interface {
 	// Execute returns the rendered template of the Python script used to spawn
 	// a child process for training.
 	Execute() ([]byte, error)
+	// Result returns the result of the last training run, including the
+	// logarithmic error, the best iterations and eval histories of all trained
+	// boosters, whether the trained models got saved, and timings.
+	Result() (*TrainResult, error)
 	Train() error
+	// TrainContext works like Train but kills the child process group once the
+	// given context gets canceled.
+	TrainContext(context.Context) error
+	// Validate returns an error if the configuration is not sufficient for
+	// training.
+	Validate() error
 }
 
 type Model interface {
 	// Execute returns the rendered template of the Python script used to spawn
 	// a child process for training.
 	Execute() ([]byte, error)
+	// Result returns the result of the last training run, including the
+	// logarithmic error, the best iterations and eval histories of all trained
+	// boosters, whether the trained models got saved, and timings.
+	Result() (*TrainResult, error)
 	Train() error
+	// TrainContext works like Train but kills the child process group once the
+	// given context gets canceled.
+	TrainContext(context.Context) error
+	// Validate returns an error if the configuration is not sufficient for
+	// training.
+	Validate() error
 }
 
 // Loader describes how boosters can be restored and used for predictions.
@@ -20,7 +42,7 @@ type Model interface {
 //         Buc: []string{ ... },
 //         Buf: []string{ ... },
 //         Pat: "/Users/xh3b4sd/dat/",
-//         Por: 8080,
+//         Por: 8080, // optional, picks a free port if 0
 //     }
 //
 type Loader interface {
@@ -52,6 +74,11 @@ type Loader interface {
 	//     https://xgboost.readthedocs.io/en/stable/tutorials/saving_model.html
 	//
 	Restore() error
+	// RestoreContext works like Restore but gives up waiting for the child
+	// process to become ready once the given context gets canceled, in which
+	// case the child process group is killed. The given context does not
+	// affect the lifetime of the child process once it is ready.
+	RestoreContext(context.Context) error
 	// Predict can be called to gather predictions from the underlying ensemble
 	// once the ensemble instance got bootstrapped via Restore. As per our
 	// example from above, the input data would be a mapping of inputs per
@@ -67,7 +94,33 @@ type Loader interface {
 	// For a multiclass ensemble the returned prediction should yield the
 	// predicted class in numeric representation.
 	Predict(map[string][]float32) (float32, error)
+	// PredictContext works like Predict but aborts the request to the child
+	// process once the given context gets canceled.
+	PredictContext(context.Context, map[string][]float32) (float32, error)
+	// PredictBatch works like Predict but scores all given inputs within a
+	// single round trip to the child process. The returned predictions are in
+	// the same order as the given inputs.
+	//
+	//     inp := []map[string][]float32{
+	//         { "foo": [ ... ], "bar": [ ... ], "baz": [ ... ] }, // row 0
+	//         { "foo": [ ... ], "bar": [ ... ], "baz": [ ... ] }, // row 1
+	//     }
+	//
+	PredictBatch([]map[string][]float32) ([]float32, error)
+	// PredictBatchContext works like PredictBatch but aborts the request to the
+	// child process once the given context gets canceled.
+	PredictBatchContext(context.Context, []map[string][]float32) ([]float32, error)
+	// PredictDetail works like PredictBatch but returns the detailed result of
+	// every prediction, including the raw ensemble margin and the probability
+	// before normalization.
+	PredictDetail([]map[string][]float32) ([]Prediction, error)
+	// PredictDetailContext works like PredictDetail but aborts the request to
+	// the child process once the given context gets canceled.
+	PredictDetailContext(context.Context, []map[string][]float32) ([]Prediction, error)
 	// Sigkill shuts down the spawned child process. No predictions can be made
 	// anymore after calling Sigkill.
 	Sigkill() error
+	// Validate returns an error if the configuration is not sufficient for
+	// restoring the ensemble.
+	Validate() error
 }